@@ -0,0 +1,225 @@
+package gocalc
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// structTag is the struct tag key used to rename or hide fields, as in
+// `calc:"name"` or `calc:"-"`.
+const structTag = "calc"
+
+// selectorPath returns the dotted path named by s, if s is a chain of field
+// accesses on a parameter.
+func selectorPath(s *selectorExpr) (string, bool) {
+	switch x := s.x.(type) {
+	case *paramExpr:
+		return x.identifier + "." + s.sel, true
+	case *selectorExpr:
+		if path, ok := selectorPath(x); ok {
+			return path + "." + s.sel, true
+		}
+	}
+	return "", false
+}
+
+// bound reports whether the first name of the dotted path is bound by an
+// enclosing lambda or let, which hides the path from the resolver.
+func (e *evaluator) bound(path string) bool {
+	_, ok := e.scope.lookup(path[:strings.IndexByte(path, '.')])
+	return ok
+}
+
+// field returns the field or map entry called name within x.
+func (e *evaluator) field(x interface{}, name string, pos int) interface{} {
+	if m, ok := x.(map[string]interface{}); ok {
+		if v := e.normalize(m[name]); v != nil {
+			return v
+		}
+		if e.config.nulls {
+			return Null
+		}
+		e.error("Field \"%s\" not found at %d", name, pos)
+	}
+
+	v := indirect(reflect.ValueOf(x))
+	switch v.Kind() {
+	case reflect.Map:
+		key, ok := mapKey(v.Type().Key(), name)
+		if !ok {
+			break
+		}
+		if r := v.MapIndex(key); r.IsValid() {
+			return e.value(r, name, pos)
+		}
+		if e.config.nulls {
+			return Null
+		}
+		e.error("Field \"%s\" not found at %d", name, pos)
+	case reflect.Struct:
+		if r, ok := structField(v, name); ok {
+			return e.value(r, name, pos)
+		}
+		e.error("Field \"%s\" not found in %s at %d", name, v.Type(), pos)
+	}
+
+	e.error("Cannot access field \"%s\" of %v (%T) at %d", name, x, x, pos)
+	return nil
+}
+
+// index returns the element of x at index, which is either a position
+// within a slice or array, or a map key.
+func (e *evaluator) index(x, index interface{}, pos int) interface{} {
+	v := indirect(reflect.ValueOf(x))
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, ok := sliceIndex(demote(index))
+		if !ok {
+			e.error("Index must be an integer, got %v (%T) at %d", index, index, pos)
+		}
+		if i < 0 || i >= int64(v.Len()) {
+			e.error("Index %v out of range [0, %d) at %d", index, v.Len(), pos)
+		}
+		return e.value(v.Index(int(i)), index, pos)
+	case reflect.Map:
+		key, ok := mapKey(v.Type().Key(), demote(index))
+		if !ok {
+			e.error("Invalid key %v (%T) for %T at %d", index, index, x, pos)
+		}
+		if r := v.MapIndex(key); r.IsValid() {
+			return e.value(r, index, pos)
+		}
+		if e.config.nulls {
+			return Null
+		}
+		e.error("Key %v not found at %d", index, pos)
+	}
+
+	e.error("Cannot index %v (%T) at %d", x, x, pos)
+	return nil
+}
+
+// sliceIndex converts the integer index to an int64. ok is false if index is
+// not an integer. An integer beyond the range of int64 becomes -1, which is
+// out of range of every slice.
+func sliceIndex(index interface{}) (i int64, ok bool) {
+	switch r := index.(type) {
+	case int64:
+		return r, true
+	case uint64:
+		if r > math.MaxInt64 {
+			return -1, true
+		}
+		return int64(r), true
+	case *big.Int:
+		return -1, true
+	}
+	return 0, false
+}
+
+// value returns the evaluator value held by v, the element accessed by
+// key.
+func (e *evaluator) value(v reflect.Value, key interface{}, pos int) interface{} {
+	if !v.CanInterface() {
+		e.error("Cannot access unexported %v at %d", key, pos)
+	}
+	r := e.normalize(v.Interface())
+	if r == nil {
+		if e.config.nulls {
+			return Null
+		}
+		e.error("Value of %v is nil at %d", key, pos)
+	}
+	return r
+}
+
+// mapKey converts an evaluator value to a key of type t, if it represents
+// one.
+func mapKey(t reflect.Type, key interface{}) (reflect.Value, bool) {
+	k := reflect.New(t).Elem()
+	switch r := key.(type) {
+	case string:
+		if t.Kind() == reflect.String {
+			k.SetString(r)
+			return k, true
+		}
+	case int64:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !k.OverflowInt(r) {
+				k.SetInt(r)
+				return k, true
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if r >= 0 && !k.OverflowUint(uint64(r)) {
+				k.SetUint(uint64(r))
+				return k, true
+			}
+		}
+	case uint64:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if r <= math.MaxInt64 && !k.OverflowInt(int64(r)) {
+				k.SetInt(int64(r))
+				return k, true
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if !k.OverflowUint(r) {
+				k.SetUint(r)
+				return k, true
+			}
+		}
+	}
+
+	if v := reflect.ValueOf(key); v.IsValid() && v.Type().AssignableTo(t) {
+		return v, true
+	}
+	return k, false
+}
+
+// indirect follows pointers and interfaces to the value they refer to.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// structField looks up the exported field of v called name, preferring a
+// field tagged with that name over one declared with it.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	var byName []int
+	for _, f := range reflect.VisibleFields(v.Type()) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get(structTag)
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			tag = tag[:i]
+		}
+		switch {
+		case tag == "-":
+			continue
+		case tag == name:
+			return fieldByIndex(v, f.Index)
+		case tag == "" && f.Name == name && byName == nil:
+			byName = f.Index
+		}
+	}
+
+	if byName != nil {
+		return fieldByIndex(v, byName)
+	}
+	return reflect.Value{}, false
+}
+
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	f, err := v.FieldByIndexErr(index)
+	return f, err == nil
+}
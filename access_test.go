@@ -0,0 +1,108 @@
+package gocalc
+
+import (
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	Country string `calc:"country"`
+	Zip     string
+	Secret  string `calc:"-"`
+	street  string
+}
+
+type testCustomer struct {
+	testAddress
+	Name    string
+	Address *testAddress `calc:"address"`
+	Scores  []int
+	Totals  map[int]float64
+}
+
+func accessResolver(s string) interface{} {
+	switch s {
+	case "customer":
+		return &testCustomer{
+			testAddress: testAddress{Country: "NZ"},
+			Name:        "Ada",
+			Address:     &testAddress{Country: "CA", Zip: "N2L", Secret: "x", street: "King"},
+			Scores:      []int{7, 9},
+			Totals:      map[int]float64{2024: 1.5},
+		}
+	case "items":
+		return []interface{}{
+			map[string]interface{}{"price": 10},
+			map[string]interface{}{"price": 2.5},
+		}
+	case "tags":
+		return map[string]string{"env": "prod"}
+	case "order":
+		return map[string]interface{}{"total": 3}
+	case "order.id":
+		return 42
+	}
+	return nil
+}
+
+var accessTests = []struct {
+	ok     bool
+	expr   string
+	expect interface{}
+	err    string
+}{
+	{true, "customer.address.country", "CA", ""},
+	{true, "customer.address.Zip", "N2L", ""},
+	{true, "customer.Name", "Ada", ""},
+	{true, "customer.country", "NZ", ""},
+	{true, "customer.Scores[1] * 2", int64(18), ""},
+	{true, "customer.Totals[2024]", 1.5, ""},
+	{true, "items[0].price + items[1].price", 12.5, ""},
+	{true, "items[1][\"price\"]", 2.5, ""},
+	{true, `tags["env"] = "prod"`, true, ""},
+	{true, "tags.env", "prod", ""},
+	{true, "order.total", int64(3), ""},
+	{true, "order.id", int64(42), ""},
+	{true, "(order).total", int64(3), ""},
+	{true, "customer.Scores[1u] * 2", int64(18), ""},
+	{true, "items[0u].price", int64(10), ""},
+	{true, "customer.Totals[2024u]", 1.5, ""},
+
+	{false, "customer.Address", nil, "at 9"},
+	{false, "customer.address.Secret", nil, "at 17"},
+	{false, "customer.address.street", nil, "at 17"},
+	{false, "customer.address.missing", nil, "not found in gocalc.testAddress at 17"},
+	{false, "items[2]", nil, "out of range [0, 2) at 5"},
+	{false, "items[-1]", nil, "at 5"},
+	{false, "items[1.0]", nil, "must be an integer"},
+	{false, "items[18446744073709551615u]", nil, "Index 18446744073709551615 out of range"},
+	{false, `tags["dev"]`, nil, "not found at 4"},
+	{false, "tags[1]", nil, "Invalid key"},
+	{false, "order.total.x", nil, "Cannot access field \"x\""},
+	{false, "order[0]", nil, "Invalid key"},
+	{false, "customer.Totals[2023]", nil, "Key 2023 not found"},
+	{false, "(1)[0]", nil, "Cannot index"},
+}
+
+func TestAccess(t *testing.T) {
+	for _, test := range accessTests {
+		err := checkEvaluation(t, expressionTest{test.ok, test.expr, test.expect}, accessResolver, nil)
+		if !test.ok && err != nil && !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expression \"%v\": expected error containing %q, got %q", test.expr, test.err, err)
+		}
+	}
+}
+
+func TestAccessMissingAsNull(t *testing.T) {
+	for _, test := range []expressionTest{
+		{true, "order.missing", Null},
+		{true, `order["missing"]`, Null},
+		{true, "tags.dev", Null},
+		{true, `tags["dev"]`, Null},
+		{true, "customer.Totals[2023]", Null},
+		{false, "customer.address.missing", nil},
+		{false, "items[2]", nil},
+	} {
+		checkEvaluation(t, test, accessResolver, nil, WithMissingAsNull())
+	}
+}
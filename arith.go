@@ -0,0 +1,585 @@
+package gocalc
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A numericRank orders the numeric types of the evaluator. When a binary
+// operator is applied to two numbers of different types, the one of lower
+// rank is converted to the type of the other.
+type numericRank int
+
+const (
+	rankNone    numericRank = iota // not a number
+	rankInt                        // int64
+	rankUint                       // uint64
+	rankBigInt                     // *big.Int
+	rankFloat                      // float64
+	rankDecimal                    // Decimal
+	rankRat                        // *big.Rat
+	rankComplex                    // complex128
+)
+
+func rank(v interface{}) numericRank {
+	switch v.(type) {
+	case int64:
+		return rankInt
+	case uint64:
+		return rankUint
+	case *big.Int:
+		return rankBigInt
+	case float64:
+		return rankFloat
+	case Decimal:
+		return rankDecimal
+	case *big.Rat:
+		return rankRat
+	case complex128:
+		return rankComplex
+	}
+	return rankNone
+}
+
+// convert converts the number v to the type of rank to, which is at least
+// v's own rank.
+func (e *evaluator) convert(v interface{}, to numericRank) interface{} {
+	switch to {
+	case rankUint:
+		switch r := v.(type) {
+		case int64:
+			if r < 0 {
+				e.error("Cannot use negative %d as an unsigned integer", r)
+			}
+			return uint64(r)
+		}
+	case rankBigInt:
+		switch r := v.(type) {
+		case int64:
+			return big.NewInt(r)
+		case uint64:
+			return new(big.Int).SetUint64(r)
+		}
+	case rankFloat:
+		switch r := v.(type) {
+		case int64:
+			return float64(r)
+		case uint64:
+			return float64(r)
+		case *big.Int:
+			f, _ := new(big.Float).SetInt(r).Float64()
+			return f
+		}
+	case rankDecimal:
+		switch r := v.(type) {
+		case int64:
+			return decimalFromInt(r)
+		case uint64:
+			return Decimal{new(big.Int).SetUint64(r), 0}
+		case *big.Int:
+			return Decimal{r, 0}
+		case float64:
+			return e.decimal(r)
+		}
+	case rankRat:
+		return e.rat(v)
+	case rankComplex:
+		c, _ := toComplex(v)
+		return c
+	}
+	return v
+}
+
+// promote converts left and right to a common numeric type. ok is false if
+// either is not a number.
+func (e *evaluator) promote(left, right interface{}) (l, r interface{}, ok bool) {
+	lr, rr := rank(left), rank(right)
+	if lr == rankNone || rr == rankNone {
+		return left, right, false
+	}
+	to := lr
+	if rr > to {
+		to = rr
+	}
+	return e.convert(left, to), e.convert(right, to), true
+}
+
+// binary applies the binary operator op to left and right.
+func (e *evaluator) binary(op *token, left, right interface{}) interface{} {
+	if op.typ == tokenOperator {
+		return e.binaryOperator(op, left, right)
+	}
+	if left == Null || right == Null {
+		return e.nullBinary(op, left, right)
+	}
+	// Intervals may compare as neither equal nor unequal, so they are
+	// handled ahead of equal.
+	if r := e.interval(op, left, right); r != nil {
+		return r
+	}
+
+	var result interface{}
+
+	switch op.typ {
+	case tokenLogicalOr, tokenLogicalAnd:
+		l, lok := left.(bool)
+		r, rok := right.(bool)
+		if lok && rok {
+			if op.typ == tokenLogicalOr {
+				result = l || r
+			} else {
+				result = l && r
+			}
+		}
+	case tokenEqual:
+		result = e.equal(left, right)
+	case tokenNotEqual:
+		result = !e.equal(left, right)
+	case tokenLeftShift, tokenRightShift:
+		result = e.shift(op, left, right)
+	case tokenIn:
+		result = e.in(left, right)
+	case tokenNotIn:
+		if r := e.in(left, right); r != nil {
+			result = !r.(bool)
+		}
+	case tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual:
+		if l, ok := left.(string); ok {
+			if r, ok := right.(string); ok {
+				result = compareBinary(op, strings.Compare(l, r))
+				break
+			}
+		}
+		if signMismatch(left, right) {
+			left, right = e.convert(left, rankBigInt), e.convert(right, rankBigInt)
+		}
+		fallthrough
+	case tokenBitwiseOr, tokenBitwiseAnd, tokenBitwiseXor,
+		tokenPlus, tokenMinus, tokenStar, tokenSlash, tokenPercent:
+		if l, r, ok := e.promote(left, right); ok {
+			switch l := l.(type) {
+			case int64:
+				result = e.intBinary(op, l, r.(int64))
+			case uint64:
+				result = e.uintBinary(op, l, r.(uint64))
+			case *big.Int:
+				result = e.bigIntBinary(op, l, r.(*big.Int))
+			case float64:
+				result = floatBinary(op, l, r.(float64))
+			case Decimal:
+				result = e.decimalBinary(op, l, r.(Decimal))
+			case *big.Rat:
+				result = e.ratBinary(op, l, r.(*big.Rat))
+			case complex128:
+				result = complexBinary(op, l, r.(complex128))
+			}
+		}
+	default:
+		e.error("Unsupported binary operator %v", op)
+	}
+
+	if result == nil {
+		result = e.temporal(op, left, right)
+	}
+	if result == nil {
+		result = e.quantity(op, left, right)
+	}
+	if result == nil {
+		result = e.overload(op, left, right)
+	}
+	if result == nil {
+		e.error("Binary operation type error; left: %v (%T), right: %v (%T), op: %v",
+			left, left, right, right, op)
+	}
+	return result
+}
+
+// equal reports whether left and right are equal. Numbers of different
+// types are compared by value.
+func (e *evaluator) equal(left, right interface{}) bool {
+	if signMismatch(left, right) {
+		return false
+	}
+	if l, ok := left.([]interface{}); ok {
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !e.equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if l, ok := left.(map[string]interface{}); ok {
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for k, v := range l {
+			if rv, ok := r[k]; !ok || !e.equal(v, rv) {
+				return false
+			}
+		}
+		return true
+	}
+	if l, r, ok := e.promote(left, right); ok {
+		switch l := l.(type) {
+		case *big.Int:
+			return l.Cmp(r.(*big.Int)) == 0
+		case Decimal:
+			return l.Cmp(r.(Decimal)) == 0
+		case *big.Rat:
+			return l.Cmp(r.(*big.Rat)) == 0
+		}
+		return l == r
+	}
+	if l, ok := left.(time.Time); ok {
+		r, ok := right.(time.Time)
+		return ok && l.Equal(r)
+	}
+	_, lq := left.(Quantity)
+	_, rq := right.(Quantity)
+	if lq || rq {
+		l, lok := e.asQuantity(left)
+		r, rok := e.asQuantity(right)
+		return lok && rok && e.equalQuantities(l, r)
+	}
+	if c, err := compare(left, right); err == nil {
+		return c == 0
+	}
+
+	if left == nil || right == nil {
+		return left == right
+	}
+	lt, rt := reflect.TypeOf(left), reflect.TypeOf(right)
+	if lt != rt {
+		return false
+	}
+	if lt.Comparable() {
+		return left == right
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compareBinary applies the relational operator op to operands that
+// compare as c, which is -1, 0 or 1.
+func compareBinary(op *token, c int) interface{} {
+	switch op.typ {
+	case tokenLessThan:
+		return c < 0
+	case tokenLessOrEqual:
+		return c <= 0
+	case tokenGreaterThan:
+		return c > 0
+	case tokenGreaterOrEqual:
+		return c >= 0
+	}
+	return nil
+}
+
+// in reports whether x is an element of the list c, a key of the map c, or
+// a substring of the string c.
+func (e *evaluator) in(x, c interface{}) interface{} {
+	switch r := c.(type) {
+	case map[string]interface{}:
+		if k, ok := x.(string); ok {
+			_, found := r[k]
+			return found
+		}
+	case []interface{}:
+		for _, elem := range r {
+			if e.equal(x, elem) {
+				return true
+			}
+		}
+		return false
+	case string:
+		if s, ok := x.(string); ok {
+			return strings.Contains(r, s)
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) intBinary(op *token, l, r int64) interface{} {
+	switch op.typ {
+	case tokenBitwiseOr:
+		return l | r
+	case tokenBitwiseAnd:
+		return l & r
+	case tokenBitwiseXor:
+		return l ^ r
+	case tokenLessThan:
+		return l < r
+	case tokenLessOrEqual:
+		return l <= r
+	case tokenGreaterThan:
+		return l > r
+	case tokenGreaterOrEqual:
+		return l >= r
+	case tokenPlus:
+		return l + r
+	case tokenMinus:
+		return l - r
+	case tokenStar:
+		return l * r
+	case tokenSlash:
+		if r == 0 {
+			e.error("Integer division by zero")
+		}
+		if e.config.decimal {
+			return e.quo(decimalFromInt(l), decimalFromInt(r))
+		}
+		return l / r
+	case tokenPercent:
+		if r == 0 {
+			e.error("Integer division by zero")
+		}
+		return l % r
+	}
+	return nil
+}
+
+func (e *evaluator) uintBinary(op *token, l, r uint64) interface{} {
+	switch op.typ {
+	case tokenBitwiseOr:
+		return l | r
+	case tokenBitwiseAnd:
+		return l & r
+	case tokenBitwiseXor:
+		return l ^ r
+	case tokenLessThan:
+		return l < r
+	case tokenLessOrEqual:
+		return l <= r
+	case tokenGreaterThan:
+		return l > r
+	case tokenGreaterOrEqual:
+		return l >= r
+	case tokenPlus:
+		return l + r
+	case tokenMinus:
+		return l - r
+	case tokenStar:
+		return l * r
+	case tokenSlash:
+		if r == 0 {
+			e.error("Integer division by zero")
+		}
+		return l / r
+	case tokenPercent:
+		if r == 0 {
+			e.error("Integer division by zero")
+		}
+		return l % r
+	}
+	return nil
+}
+
+// signMismatch reports whether one of left and right is a uint64 and the
+// other a negative int64, which have no common 64-bit type.
+func signMismatch(left, right interface{}) bool {
+	l, lok := left.(int64)
+	r, rok := right.(int64)
+	_, lu := left.(uint64)
+	_, ru := right.(uint64)
+	return lok && l < 0 && ru || rok && r < 0 && lu
+}
+
+// shift shifts left by right bits. The result has the type of left, so
+// that >> is arithmetic on an int64 and logical on a uint64.
+func (e *evaluator) shift(op *token, left, right interface{}) interface{} {
+	switch l := left.(type) {
+	case int64:
+		if n, ok := e.shiftCount(right, 63); ok {
+			if op.typ == tokenLeftShift {
+				return l << n
+			}
+			return l >> n
+		}
+	case uint64:
+		if n, ok := e.shiftCount(right, 63); ok {
+			if op.typ == tokenLeftShift {
+				return l << n
+			}
+			return l >> n
+		}
+	case *big.Int:
+		if n, ok := e.shiftCount(right, maxShift); ok {
+			if op.typ == tokenLeftShift {
+				return new(big.Int).Lsh(l, n)
+			}
+			return new(big.Int).Rsh(l, n)
+		}
+	}
+	return nil
+}
+
+// shiftCount returns the integer n as the count of a shift, which must be
+// between zero and max. ok is false if n is not an integer.
+func (e *evaluator) shiftCount(n interface{}, max uint64) (count uint, ok bool) {
+	var c uint64
+	switch r := n.(type) {
+	case int64:
+		if r < 0 {
+			e.error("Negative shift count %d", r)
+		}
+		c = uint64(r)
+	case uint64:
+		c = r
+	case *big.Int:
+		if r.Sign() < 0 {
+			e.error("Negative shift count %s", r)
+		}
+		if !r.IsUint64() {
+			e.error("Shift count %s too large", r)
+		}
+		c = r.Uint64()
+	default:
+		return 0, false
+	}
+	if c > max {
+		e.error("Shift count %d too large, must be at most %d", c, max)
+	}
+	return uint(c), true
+}
+
+func floatBinary(op *token, l, r float64) interface{} {
+	switch op.typ {
+	case tokenLessThan:
+		return l < r
+	case tokenLessOrEqual:
+		return l <= r
+	case tokenGreaterThan:
+		return l > r
+	case tokenGreaterOrEqual:
+		return l >= r
+	case tokenPlus:
+		return l + r
+	case tokenMinus:
+		return l - r
+	case tokenStar:
+		return l * r
+	case tokenSlash:
+		return l / r
+	}
+	return nil
+}
+
+func (e *evaluator) decimalBinary(op *token, l, r Decimal) interface{} {
+	switch op.typ {
+	case tokenLessThan:
+		return l.Cmp(r) < 0
+	case tokenLessOrEqual:
+		return l.Cmp(r) <= 0
+	case tokenGreaterThan:
+		return l.Cmp(r) > 0
+	case tokenGreaterOrEqual:
+		return l.Cmp(r) >= 0
+	case tokenPlus:
+		return l.Add(r)
+	case tokenMinus:
+		return l.Sub(r)
+	case tokenStar:
+		return l.Mul(r)
+	case tokenSlash:
+		if r.Sign() == 0 {
+			e.error("Decimal division by zero")
+		}
+		return e.quo(l, r)
+	case tokenPercent:
+		if r.Sign() == 0 {
+			e.error("Decimal division by zero")
+		}
+		return l.Rem(r)
+	}
+	return nil
+}
+
+// quo divides Decimals using the precision and rounding of the evaluator.
+func (e *evaluator) quo(l, r Decimal) Decimal {
+	return l.Quo(r, e.config.places, e.config.rounding)
+}
+
+// decimal converts a float to a Decimal.
+func (e *evaluator) decimal(f float64) Decimal {
+	d, err := decimalFromFloat(f)
+	if err != nil {
+		e.error("%s", err)
+	}
+	return d
+}
+
+// unary applies the unary operator op to operand.
+func (e *evaluator) unary(op *token, operand interface{}) interface{} {
+	if op.typ == tokenOperator {
+		return e.unaryOperator(op, operand)
+	}
+	if operand == Null {
+		return Null
+	}
+
+	var result interface{}
+
+	switch op.typ {
+	case tokenPlus:
+		switch operand.(type) {
+		case time.Duration, Quantity, Interval:
+			result = operand
+		default:
+			if rank(operand) != rankNone {
+				result = operand
+			}
+		}
+	case tokenMinus:
+		switch r := operand.(type) {
+		case int64:
+			result = -r
+		case uint64:
+			result = -r
+		case float64:
+			result = -r
+		case Decimal:
+			result = r.Neg()
+		case *big.Int:
+			result = new(big.Int).Neg(r)
+		case *big.Rat:
+			result = new(big.Rat).Neg(r)
+		case complex128:
+			result = -r
+		case time.Duration:
+			result = -r
+		case Quantity:
+			result = Quantity{e.unary(op, r.Value), r.Unit}
+		case Interval:
+			result = Interval{-r.Hi, -r.Lo}
+		case Negater:
+			result, _ = e.overloaded(r.Neg())
+		}
+	case tokenLogicalNot, tokenNot:
+		switch r := operand.(type) {
+		case bool:
+			result = !r
+		}
+	case tokenBitwiseNot:
+		switch r := operand.(type) {
+		case int64:
+			result = ^r
+		case uint64:
+			result = ^r
+		case *big.Int:
+			result = new(big.Int).Not(r)
+		}
+	default:
+		e.error("Unsupported unary operator %v", op)
+	}
+
+	if result == nil {
+		e.error("Unary operation type mismatch; operator: %v, operand: %v (%T)", op, operand, operand)
+	}
+	return result
+}
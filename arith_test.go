@@ -0,0 +1,67 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+var unsignedTests = []expressionTest{
+	{true, "42u", uint64(42)},
+	{true, "0xffu", uint64(255)},
+	{true, "0b101u", uint64(5)},
+	{true, "0xffffffffffffffffu", uint64(math.MaxUint64)},
+	{true, "0xffffffffffffffffu >> 60", uint64(15)},
+	{true, "-16 >> 2", int64(-4)},
+	{true, "u64(-16) >> 60", uint64(15)},
+	{true, "1u << 63", uint64(1 << 63)},
+	{true, "0xf0u ^ 0xffu", uint64(15)},
+	{true, "~0u", uint64(math.MaxUint64)},
+	{true, "0u - 1", uint64(math.MaxUint64)},
+	{true, "7u / 2", uint64(3)},
+	{true, "7u % 4u", uint64(3)},
+	{true, "3u + 2.5", 5.5},
+	{true, "0xffffffffffffffffu > 1", true},
+	{true, "1u > -1", true},
+	{true, "-1 < 1u", true},
+	{true, "-1 = 0xffffffffffffffffu", false},
+	{true, "1u = 1", true},
+	{true, "u8(300)", uint64(44)},
+	{true, "u8(-1)", uint64(255)},
+	{true, "u16(0x12345)", uint64(0x2345)},
+	{true, "u32(-1)", uint64(math.MaxUint32)},
+	{true, "i8(200)", int64(-56)},
+	{true, "i8(127)", int64(127)},
+	{true, "i16(0xffff)", int64(-1)},
+	{true, "i32(0x80000000)", int64(math.MinInt32)},
+	{true, "i64(0xffffffffffffffffu)", int64(-1)},
+	{true, "u32(3.9)", uint64(3)},
+	{true, "i32(-3.9)", int64(-3)},
+	{true, "u8(1 << 8 | 7)", uint64(7)},
+	{false, "1u - -1", nil},
+	{false, "1u / 0u", nil},
+	{false, "1 << -1", nil},
+	{false, "1 >> 64", nil},
+	{false, "1u << 64", nil},
+	{false, "1 << 1.5", nil},
+	{false, "u8(1e300 * 1e300)", nil},
+	{false, "i8(true)", nil},
+	{false, "18446744073709551616u", nil},
+}
+
+func TestUnsigned(t *testing.T) {
+	for _, test := range unsignedTests {
+		checkEvaluation(t, test, nil, nil)
+	}
+}
+
+func TestUnsignedBigNumbers(t *testing.T) {
+	e, err := NewExpr("(1 << 64) + 0xffffffffffffffffu >> 1u", WithBigNumbers(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := e.Evaluate(nil, nil)
+	if s := fmt.Sprint(res); err != nil || s != "18446744073709551615" {
+		t.Errorf("Evaluation returned %v (%T), %v", res, res, err)
+	}
+}
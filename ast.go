@@ -1,5 +1,10 @@
 package gocalc
 
+import (
+	"math/big"
+	"time"
+)
+
 // All expressions implement the expr interface.
 type expr interface {
 	accept(exprVisitor)
@@ -13,6 +18,13 @@ type (
 		right expr   // right operand
 	}
 
+	// A compareExpr represents a chain of comparisons, like a < b <= c,
+	// which holds if each operand compares with the next.
+	compareExpr struct {
+		operands []expr   // compared operands, one more than ops
+		ops      []*token // relational operators
+	}
+
 	// A funcExpr represents a function call.
 	funcExpr struct {
 		function string // function name
@@ -24,32 +36,150 @@ type (
 		identifier string // parameter name
 	}
 
+	// A selectorExpr represents a field access.
+	selectorExpr struct {
+		x   expr   // operand
+		sel string // field name
+		pos int    // position of field name
+	}
+
+	// An indexExpr represents an index or key lookup.
+	indexExpr struct {
+		x      expr // operand
+		index  expr // index or key
+		lbrack int  // position of "["
+	}
+
+	// An isNullExpr represents an `x is null` or `x is not null` test.
+	isNullExpr struct {
+		x   expr
+		not bool
+	}
+
+	// A betweenExpr represents an `x between low and high` or
+	// `x not between low and high` test.
+	betweenExpr struct {
+		x, low, high expr
+		not          bool
+	}
+
 	// A unaryExpr represents a unary expression.
 	unaryExpr struct {
 		expr expr   // operand
 		op   *token // unary operator
 	}
 
+	// A listExpr represents a list literal, like [1, 2, 3].
+	listExpr struct {
+		elems  []expr
+		lbrack int
+	}
+
+	// A mapExpr represents a map literal, like {"gold": 0.2}.
+	mapExpr struct {
+		keys   []string
+		values []expr
+		lbrace int
+	}
+
+	// A lambdaExpr represents an anonymous function, like (a, b) => a + b.
+	lambdaExpr struct {
+		params []string // parameter names
+		body   expr
+		pos    int // starting position of lambda
+	}
+
+	// A letExpr represents local bindings, like let x = a * b in x + 1.
+	letExpr struct {
+		names  []string // bound names
+		values []expr   // bound values
+		body   expr
+	}
+
+	// A programExpr represents the statements of a program compiled with
+	// WithProgram.
+	programExpr struct {
+		stmts []expr
+	}
+
+	// An assignExpr represents an assignment statement, like x := 1.
+	assignExpr struct {
+		name  string // assigned name
+		value expr
+	}
+
 	// A boolExpr represents a boolean literal.
 	boolExpr struct {
 		val bool
 	}
 
+	// A nullExpr represents the null literal.
+	nullExpr struct{}
+
 	// A floatExpr represents a float literal.
 	floatExpr struct {
 		val float64
 	}
 
+	// A complexExpr represents an imaginary literal, like 2.5i.
+	complexExpr struct {
+		val complex128
+	}
+
+	// A decimalExpr represents a float literal compiled with WithDecimal.
+	decimalExpr struct {
+		val Decimal
+	}
+
 	// An intExpr represents a integer literal.
 	intExpr struct {
 		val int64
 	}
+
+	// A uintExpr represents an unsigned integer literal, like 0xffu.
+	uintExpr struct {
+		val uint64
+	}
+
+	// A bigIntExpr represents an integer literal compiled with
+	// WithBigNumbers.
+	bigIntExpr struct {
+		val *big.Int
+	}
+
+	// A durationExpr represents a duration literal, like 90s or 1h30m.
+	durationExpr struct {
+		val time.Duration
+	}
+
+	// A quantityExpr represents a quantity literal, like 5[km], whose
+	// number is x.
+	quantityExpr struct {
+		x    expr
+		unit string
+	}
+
+	// A stringExpr represents a string literal.
+	stringExpr struct {
+		val string
+	}
+
+	// A badExpr is a placeholder for an expression containing syntax
+	// errors, so that parsing can continue past them.
+	badExpr struct {
+		pos int // starting position of bad expression
+		end int // ending position of bad expression
+	}
 )
 
 func (b *binaryExpr) accept(v exprVisitor) {
 	v.visitBinaryExpr(b)
 }
 
+func (c *compareExpr) accept(v exprVisitor) {
+	v.visitCompareExpr(c)
+}
+
 func (f *funcExpr) accept(v exprVisitor) {
 	v.visitFuncExpr(f)
 }
@@ -58,18 +188,94 @@ func (p *paramExpr) accept(v exprVisitor) {
 	v.visitParamExpr(p)
 }
 
+func (s *selectorExpr) accept(v exprVisitor) {
+	v.visitSelectorExpr(s)
+}
+
+func (i *indexExpr) accept(v exprVisitor) {
+	v.visitIndexExpr(i)
+}
+
+func (i *isNullExpr) accept(v exprVisitor) {
+	v.visitIsNullExpr(i)
+}
+
+func (b *betweenExpr) accept(v exprVisitor) {
+	v.visitBetweenExpr(b)
+}
+
 func (u *unaryExpr) accept(v exprVisitor) {
 	v.visitUnaryExpr(u)
 }
 
+func (l *listExpr) accept(v exprVisitor) {
+	v.visitListExpr(l)
+}
+
+func (m *mapExpr) accept(v exprVisitor) {
+	v.visitMapExpr(m)
+}
+
+func (l *lambdaExpr) accept(v exprVisitor) {
+	v.visitLambdaExpr(l)
+}
+
+func (l *letExpr) accept(v exprVisitor) {
+	v.visitLetExpr(l)
+}
+
+func (p *programExpr) accept(v exprVisitor) {
+	v.visitProgramExpr(p)
+}
+
+func (a *assignExpr) accept(v exprVisitor) {
+	v.visitAssignExpr(a)
+}
+
 func (b *boolExpr) accept(v exprVisitor) {
 	v.visitBoolExpr(b)
 }
 
+func (n *nullExpr) accept(v exprVisitor) {
+	v.visitNullExpr(n)
+}
+
 func (f *floatExpr) accept(v exprVisitor) {
 	v.visitFloatExpr(f)
 }
 
+func (c *complexExpr) accept(v exprVisitor) {
+	v.visitComplexExpr(c)
+}
+
+func (d *decimalExpr) accept(v exprVisitor) {
+	v.visitDecimalExpr(d)
+}
+
 func (i *intExpr) accept(v exprVisitor) {
 	v.visitIntExpr(i)
 }
+
+func (u *uintExpr) accept(v exprVisitor) {
+	v.visitUintExpr(u)
+}
+
+func (b *bigIntExpr) accept(v exprVisitor) {
+	v.visitBigIntExpr(b)
+}
+
+func (d *durationExpr) accept(v exprVisitor) {
+	v.visitDurationExpr(d)
+}
+
+func (q *quantityExpr) accept(v exprVisitor) {
+	v.visitQuantityExpr(q)
+}
+
+func (s *stringExpr) accept(v exprVisitor) {
+	v.visitStringExpr(s)
+}
+
+func (b *badExpr) accept(v exprVisitor) {
+	v.visitBadExpr(b)
+}
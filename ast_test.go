@@ -10,13 +10,33 @@ func (m *mockExprVisitor) add(e expr) {
 	m.visited = append(m.visited, e)
 }
 
-func (m *mockExprVisitor) visitBinaryExpr(b *binaryExpr) { m.add(b) }
-func (m *mockExprVisitor) visitFuncExpr(f *funcExpr)     { m.add(f) }
-func (m *mockExprVisitor) visitParamExpr(p *paramExpr)   { m.add(p) }
-func (m *mockExprVisitor) visitUnaryExpr(u *unaryExpr)   { m.add(u) }
-func (m *mockExprVisitor) visitBoolExpr(b *boolExpr)     { m.add(b) }
-func (m *mockExprVisitor) visitFloatExpr(f *floatExpr)   { m.add(f) }
-func (m *mockExprVisitor) visitIntExpr(i *intExpr)       { m.add(i) }
+func (m *mockExprVisitor) visitBinaryExpr(b *binaryExpr)     { m.add(b) }
+func (m *mockExprVisitor) visitCompareExpr(c *compareExpr)   { m.add(c) }
+func (m *mockExprVisitor) visitFuncExpr(f *funcExpr)         { m.add(f) }
+func (m *mockExprVisitor) visitParamExpr(p *paramExpr)       { m.add(p) }
+func (m *mockExprVisitor) visitSelectorExpr(s *selectorExpr) { m.add(s) }
+func (m *mockExprVisitor) visitIndexExpr(i *indexExpr)       { m.add(i) }
+func (m *mockExprVisitor) visitIsNullExpr(i *isNullExpr)     { m.add(i) }
+func (m *mockExprVisitor) visitBetweenExpr(b *betweenExpr)   { m.add(b) }
+func (m *mockExprVisitor) visitUnaryExpr(u *unaryExpr)       { m.add(u) }
+func (m *mockExprVisitor) visitListExpr(l *listExpr)         { m.add(l) }
+func (m *mockExprVisitor) visitMapExpr(e *mapExpr)           { m.add(e) }
+func (m *mockExprVisitor) visitLambdaExpr(l *lambdaExpr)     { m.add(l) }
+func (m *mockExprVisitor) visitLetExpr(l *letExpr)           { m.add(l) }
+func (m *mockExprVisitor) visitProgramExpr(p *programExpr)   { m.add(p) }
+func (m *mockExprVisitor) visitAssignExpr(a *assignExpr)     { m.add(a) }
+func (m *mockExprVisitor) visitBoolExpr(b *boolExpr)         { m.add(b) }
+func (m *mockExprVisitor) visitNullExpr(n *nullExpr)         { m.add(n) }
+func (m *mockExprVisitor) visitFloatExpr(f *floatExpr)       { m.add(f) }
+func (m *mockExprVisitor) visitComplexExpr(c *complexExpr)   { m.add(c) }
+func (m *mockExprVisitor) visitDecimalExpr(d *decimalExpr)   { m.add(d) }
+func (m *mockExprVisitor) visitIntExpr(i *intExpr)           { m.add(i) }
+func (m *mockExprVisitor) visitUintExpr(u *uintExpr)         { m.add(u) }
+func (m *mockExprVisitor) visitBigIntExpr(b *bigIntExpr)     { m.add(b) }
+func (m *mockExprVisitor) visitDurationExpr(d *durationExpr) { m.add(d) }
+func (m *mockExprVisitor) visitQuantityExpr(q *quantityExpr) { m.add(q) }
+func (m *mockExprVisitor) visitStringExpr(s *stringExpr)     { m.add(s) }
+func (m *mockExprVisitor) visitBadExpr(b *badExpr)           { m.add(b) }
 
 func newMockExprVisitor() *mockExprVisitor {
 	return &mockExprVisitor{}
@@ -24,12 +44,32 @@ func newMockExprVisitor() *mockExprVisitor {
 
 var acceptExprs = []expr{
 	&binaryExpr{},
+	&compareExpr{},
 	&funcExpr{},
 	&paramExpr{},
+	&selectorExpr{},
+	&indexExpr{},
+	&isNullExpr{},
+	&betweenExpr{},
 	&unaryExpr{},
+	&listExpr{},
+	&mapExpr{},
+	&lambdaExpr{},
+	&letExpr{},
+	&programExpr{},
+	&assignExpr{},
 	&boolExpr{},
+	&nullExpr{},
 	&floatExpr{},
+	&complexExpr{},
+	&decimalExpr{},
 	&intExpr{},
+	&uintExpr{},
+	&bigIntExpr{},
+	&durationExpr{},
+	&quantityExpr{},
+	&stringExpr{},
+	&badExpr{},
 }
 
 func TestAccepts(t *testing.T) {
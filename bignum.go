@@ -0,0 +1,127 @@
+package gocalc
+
+import "math/big"
+
+// maxShift bounds the shift count of a *big.Int, so that an expression
+// like 1 << 1e15 fails rather than exhausting memory.
+const maxShift = 1 << 20
+
+func (e *evaluator) bigIntBinary(op *token, l, r *big.Int) interface{} {
+	switch op.typ {
+	case tokenBitwiseOr:
+		return new(big.Int).Or(l, r)
+	case tokenBitwiseAnd:
+		return new(big.Int).And(l, r)
+	case tokenBitwiseXor:
+		return new(big.Int).Xor(l, r)
+	case tokenLessThan:
+		return l.Cmp(r) < 0
+	case tokenLessOrEqual:
+		return l.Cmp(r) <= 0
+	case tokenGreaterThan:
+		return l.Cmp(r) > 0
+	case tokenGreaterOrEqual:
+		return l.Cmp(r) >= 0
+	case tokenPlus:
+		return new(big.Int).Add(l, r)
+	case tokenMinus:
+		return new(big.Int).Sub(l, r)
+	case tokenStar:
+		return new(big.Int).Mul(l, r)
+	case tokenSlash:
+		if r.Sign() == 0 {
+			e.error("Integer division by zero")
+		}
+		return new(big.Rat).SetFrac(l, r)
+	case tokenPercent:
+		if r.Sign() == 0 {
+			e.error("Integer division by zero")
+		}
+		return new(big.Int).Rem(l, r)
+	}
+	return nil
+}
+
+func (e *evaluator) ratBinary(op *token, l, r *big.Rat) interface{} {
+	switch op.typ {
+	case tokenLessThan:
+		return l.Cmp(r) < 0
+	case tokenLessOrEqual:
+		return l.Cmp(r) <= 0
+	case tokenGreaterThan:
+		return l.Cmp(r) > 0
+	case tokenGreaterOrEqual:
+		return l.Cmp(r) >= 0
+	case tokenPlus:
+		return new(big.Rat).Add(l, r)
+	case tokenMinus:
+		return new(big.Rat).Sub(l, r)
+	case tokenStar:
+		return new(big.Rat).Mul(l, r)
+	case tokenSlash:
+		if r.Sign() == 0 {
+			e.error("Rational division by zero")
+		}
+		return new(big.Rat).Quo(l, r)
+	}
+	return nil
+}
+
+// rat converts a number to a *big.Rat.
+func (e *evaluator) rat(v interface{}) *big.Rat {
+	switch r := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(r)
+	case uint64:
+		return new(big.Rat).SetUint64(r)
+	case *big.Int:
+		return new(big.Rat).SetInt(r)
+	case float64:
+		if q := new(big.Rat).SetFloat64(r); q != nil {
+			return q
+		}
+		e.error("Cannot represent %v as a fraction", r)
+	case Decimal:
+		return new(big.Rat).SetFrac(r.int(), pow10(r.scale))
+	case *big.Rat:
+		return r
+	}
+	return nil
+}
+
+// roundRat returns x rounded to places digits after the decimal point
+// using mode. A negative places rounds to the left of the decimal point.
+func roundRat(x *big.Rat, places int, mode RoundingMode) Decimal {
+	num, div := new(big.Int).Set(x.Num()), new(big.Int).Set(x.Denom())
+	if places >= 0 {
+		num.Mul(num, pow10(places))
+	} else {
+		div.Mul(div, pow10(-places))
+	}
+
+	q, r := new(big.Int).QuoRem(num, div, new(big.Int))
+	if r.Sign() != 0 && roundAway(q, r, div, mode) {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return newDecimal(q, places)
+}
+
+// demote returns v as an int64 if it is an integer that fits in one.
+// Fractions that are integers become *big.Int if they do not.
+func demote(v interface{}) interface{} {
+	switch r := v.(type) {
+	case *big.Rat:
+		if r.IsInt() {
+			return demote(new(big.Int).Set(r.Num()))
+		}
+	case *big.Int:
+		if r.IsInt64() {
+			return r.Int64()
+		}
+	}
+	return v
+}
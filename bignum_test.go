@@ -0,0 +1,132 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+var bigNumberTests = []struct {
+	ok     bool
+	expr   string
+	demote bool
+	expect interface{}
+}{
+	{true, "9223372036854775807 + 1", false, bigInt("9223372036854775808")},
+	{true, "340282366920938463463374607431768211456 / 2", false, bigRat("170141183460469231731687303715884105728/1")},
+	{true, "1 / 3 + 1 / 6", false, bigRat("1/2")},
+	{true, "1 / 3 + 1 / 6", true, bigRat("1/2")},
+	{true, "1 / 3 * 3", true, 1},
+	{true, "1 << 100", false, bigInt("1267650600228229401496703205376")},
+	{true, "(1 << 100) >> 99", true, 2},
+	{true, "(1 << 64) | 1 & 3 ^ 2", false, bigInt("18446744073709551619")},
+	{true, "~(1 << 64)", false, bigInt("-18446744073709551617")},
+	{true, "-(1 << 64) % 7", false, bigInt("-2")},
+	{true, "(1 << 64) > (1 << 63)", false, true},
+	{true, "2 / 4 = 0.5", false, true},
+	{true, "1 / 3 < 0.34", false, true},
+	{true, "1 / 4 + 0.25", false, bigRat("1/2")},
+	{true, "(1 << 64) + 0.5", false, 1.8446744073709552e+19},
+	{true, "huge + 1", false, bigInt("18446744073709551616")},
+	{true, "num * 2", true, bigInt("24691357802469135780246913578")},
+	{true, "half + half", true, 1},
+	{true, "small * 2", true, 84},
+	{true, "list[4 / 2]", true, 3},
+	{true, "factorial(25)", false, bigInt("15511210043330985984000000")},
+	{true, "factorial(5)", true, 120},
+	{true, "abs(-1 / 3)", false, bigRat("1/3")},
+	{true, "round(2 / 3, 2)", false, bigRat("67/100")},
+	{true, "round(5 / 2)", false, bigInt("2")},
+	{true, "floor(-5 / 2)", false, bigInt("-3")},
+	{true, "ceil(-5 / 2)", false, bigInt("-2")},
+	{true, "round(12345, -2)", false, bigInt("12300")},
+	{false, "1 / 0", false, nil},
+	{false, "1 / 3 / 0", false, nil},
+	{false, "1 % 0", false, nil},
+	{false, "1 / 3 % 2", false, nil},
+	{false, "1 << -1", false, nil},
+	{false, "1 << (1 << 40)", false, nil},
+	{false, "round(1 / 3, 2000000000)", false, nil},
+	{false, "round(5, -2000000000)", false, nil},
+	{false, "factorial(-1)", false, nil},
+	{false, "factorial(1 / 2)", false, nil},
+}
+
+func bigInt(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("bad big.Int " + s)
+	}
+	return x
+}
+
+func bigRat(s string) *big.Rat {
+	x, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("bad big.Rat " + s)
+	}
+	return x
+}
+
+func TestBigNumbers(t *testing.T) {
+	num, _ := new(big.Int).SetString("12345678901234567890123456789", 10)
+	params := MapEnv(map[string]interface{}{
+		"huge":  uint64(math.MaxUint64),
+		"num":   num,
+		"half":  big.NewRat(1, 2),
+		"small": 42,
+		"list":  []int{1, 2, 3},
+	})
+
+	for _, test := range bigNumberTests {
+		checkEvaluation(t, expressionTest{test.ok, test.expr, test.expect}, params, nil, WithBigNumbers(test.demote))
+	}
+}
+
+func TestBigNumbersDemote(t *testing.T) {
+	for _, test := range []struct {
+		expr   string
+		expect interface{}
+	}{
+		{"2 + 3", int64(5)},
+		{"6 / 3", int64(2)},
+		{"1 << 63", "9223372036854775808"},
+		{"(1 << 64) / 2", "9223372036854775808"},
+	} {
+		e, err := NewExpr(test.expr, WithBigNumbers(true))
+		if err != nil {
+			t.Fatalf("Expression \"%v\": %v", test.expr, err)
+		}
+		res, err := e.Evaluate(nil, nil)
+		if err != nil {
+			t.Errorf("Expression \"%v\": Evaluation failed with error: %v", test.expr, err)
+		} else if s, ok := test.expect.(string); ok {
+			if r, ok := res.(*big.Int); !ok || r.String() != s {
+				t.Errorf("Expression \"%v\": Evaluation returned %v (%T), expected *big.Int %s", test.expr, res, res, s)
+			}
+		} else if res != test.expect {
+			t.Errorf("Expression \"%v\": Evaluation returned %v (%T), expected %v", test.expr, res, res, test.expect)
+		}
+	}
+}
+
+func TestBigLiteralIsNotShared(t *testing.T) {
+	e, _ := NewExpr("7", WithBigNumbers(false))
+	res, _ := e.Evaluate(nil, nil)
+	res.(*big.Int).SetInt64(0)
+
+	if res, _ := e.Evaluate(nil, nil); res.(*big.Int).Int64() != 7 {
+		t.Errorf("Modifying a result changed the literal to %v", res)
+	}
+}
+
+func ExampleWithBigNumbers() {
+	expression, _ := NewExpr("factorial(30) / factorial(28) + 1 / 3", WithBigNumbers(true))
+
+	result, _ := expression.Evaluate(nil, nil)
+	fmt.Println(result)
+
+	// Output:
+	// 2611/3
+}
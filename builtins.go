@@ -0,0 +1,406 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"time"
+)
+
+// A builtin implements a function available to every Expression. Builtins
+// are only called for functions the FuncHandler does not handle, and are
+// given their unevaluated arguments.
+type builtin func(e *evaluator, f *funcExpr) interface{}
+
+// A strictFunc implements a builtin that is given its evaluated arguments.
+type strictFunc func(e *evaluator, f *funcExpr, args []interface{}) interface{}
+
+var builtins map[string]builtin
+
+// maxArgs is the maximum of a builtin taking any number of arguments.
+const maxArgs = math.MaxInt32
+
+func init() {
+	builtins = map[string]builtin{
+		"abs":   strict(1, 1, builtinAbs),
+		"round": strict(1, 2, builtinRound),
+		"floor": strict(1, 1, builtinFloor),
+		"ceil":  strict(1, 1, builtinCeil),
+
+		"factorial": strict(1, 1, builtinFactorial),
+
+		"real": strict(1, 1, builtinReal),
+		"imag": strict(1, 1, builtinImag),
+		"conj": strict(1, 1, builtinConj),
+		"arg":  strict(1, 1, builtinArg),
+		"sqrt": strict(1, 1, builtinSqrt),
+		"exp":  strict(1, 1, builtinExp),
+		"log":  strict(1, 1, builtinLog),
+
+		"coalesce": builtinCoalesce,
+		"ifnull":   builtinIfNull,
+
+		"len":      strict(1, 1, builtinLen),
+		"sum":      strict(1, maxArgs, builtinSum),
+		"avg":      strict(1, maxArgs, builtinAvg),
+		"min":      strict(1, maxArgs, builtinMin),
+		"max":      strict(1, maxArgs, builtinMax),
+		"count":    strict(1, maxArgs, builtinCount),
+		"contains": strict(2, 2, builtinContains),
+		"any":      strict(1, 2, builtinAny),
+		"all":      strict(1, 2, builtinAll),
+		"sort":     strict(1, 1, builtinSort),
+		"distinct": strict(1, 1, builtinDistinct),
+		"slice":    strict(2, 3, builtinSlice),
+		"concat":   strict(1, maxArgs, builtinConcat),
+
+		"map":     strict(2, 2, builtinMap),
+		"filter":  strict(2, 2, builtinFilter),
+		"reduce":  builtinReduce,
+		"sortBy":  strict(2, 2, builtinSortBy),
+		"groupBy": strict(2, 2, builtinGroupBy),
+
+		"keys":   strict(1, 1, builtinKeys),
+		"values": strict(1, 1, builtinValues),
+		"has":    strict(2, 2, builtinHas),
+		"get":    builtinGet,
+
+		"now":      strict(0, 0, builtinNow),
+		"date":     strict(1, 2, builtinDate),
+		"datetime": strict(1, 2, builtinDatetime),
+		"duration": strict(1, 1, builtinDuration),
+		"year":     strict(1, 1, timePart(time.Time.Year)),
+		"month":    strict(1, 1, timePart(func(t time.Time) int { return int(t.Month()) })),
+		"day":      strict(1, 1, timePart(time.Time.Day)),
+		"weekday":  strict(1, 1, timePart(isoWeekday)),
+		"hour":     strict(1, 1, timePart(time.Time.Hour)),
+		"minute":   strict(1, 1, timePart(time.Time.Minute)),
+		"startOf":  strict(2, 2, builtinStartOf),
+
+		"to": strict(2, 2, builtinTo),
+
+		"lo":    strict(1, 1, builtinLo),
+		"hi":    strict(1, 1, builtinHi),
+		"mid":   strict(1, 1, builtinMid),
+		"width": strict(1, 1, builtinWidth),
+
+		"u8":  intCast(8, false),
+		"u16": intCast(16, false),
+		"u32": intCast(32, false),
+		"u64": intCast(64, false),
+		"i8":  intCast(8, true),
+		"i16": intCast(16, true),
+		"i32": intCast(32, true),
+		"i64": intCast(64, true),
+	}
+}
+
+// args evaluates the arguments of f, which must number between min and max.
+func (e *evaluator) args(f *funcExpr, min, max int) []interface{} {
+	if l := len(f.args); l < min || l > max {
+		switch {
+		case min == max:
+			e.error("%s takes %s, got %d", f.function, params(min), l)
+		case l < min:
+			e.error("%s takes at least %s, got %d", f.function, params(min), l)
+		default:
+			e.error("%s takes at most %s, got %d", f.function, params(max), l)
+		}
+	}
+
+	args := make([]interface{}, len(f.args))
+	for i, arg := range f.args {
+		args[i] = e.evaluate(arg)
+	}
+	return args
+}
+
+// strict returns a builtin that evaluates between min and max arguments
+// and calls fn with them, or that returns null if any of them is null.
+func strict(min, max int, fn strictFunc) builtin {
+	return func(e *evaluator, f *funcExpr) interface{} {
+		args := e.args(f, min, max)
+		for _, arg := range args {
+			if arg == Null {
+				return Null
+			}
+		}
+		return fn(e, f, args)
+	}
+}
+
+func params(n int) string {
+	if n == 1 {
+		return "one param"
+	}
+	return fmt.Sprintf("%d params", n)
+}
+
+// intArg returns args[i] as an int.
+func (e *evaluator) intArg(f *funcExpr, args []interface{}, i int) int {
+	n, ok := demote(args[i]).(int64)
+	if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+		e.error("%s requires an integer argument %d, got %v (%T)", f.function, i+1, args[i], args[i])
+	}
+	return int(n)
+}
+
+func builtinAbs(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	switch r := args[0].(type) {
+	case int64:
+		if r < 0 {
+			r = -r
+		}
+		return r
+	case uint64:
+		return r
+	case float64:
+		return math.Abs(r)
+	case Decimal:
+		return r.Abs()
+	case *big.Int:
+		return new(big.Int).Abs(r)
+	case *big.Rat:
+		return new(big.Rat).Abs(r)
+	case complex128:
+		return cmplx.Abs(r)
+	}
+	e.error("abs requires a number, got %v (%T)", args[0], args[0])
+	return nil
+}
+
+// maxPlaces bounds the places argument of round, as rounding computes
+// 10^places.
+const maxPlaces = 1000
+
+// round(x) or round(x, places) rounds x to places digits after the decimal
+// point, using the rounding mode of the Expression. A negative places
+// rounds to the left of the decimal point.
+func builtinRound(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	places := 0
+	if len(args) > 1 {
+		places = e.intArg(f, args, 1)
+		if places < -maxPlaces || places > maxPlaces {
+			e.error("round places %d out of range [%d, %d]", places, -maxPlaces, maxPlaces)
+		}
+	}
+	return e.round(f, args[0], places, e.config.rounding)
+}
+
+// floor(x) returns the greatest integer value less than or equal to x.
+func builtinFloor(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.round(f, args[0], 0, RoundFloor)
+}
+
+// ceil(x) returns the least integer value greater than or equal to x.
+func builtinCeil(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.round(f, args[0], 0, RoundCeiling)
+}
+
+// round rounds x in base 10, so that round(2.675, 2) is 2.68 with
+// RoundHalfUp even though the nearest float64 is slightly below 2.675.
+func (e *evaluator) round(f *funcExpr, x interface{}, places int, mode RoundingMode) interface{} {
+	switch r := x.(type) {
+	case int64:
+		if places >= 0 {
+			return r
+		}
+		d := decimalFromInt(r).Round(places, mode)
+		if !d.int().IsInt64() {
+			e.error("%s overflows int64", f.function)
+		}
+		return d.int().Int64()
+	case float64:
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			return r
+		}
+		return e.decimal(r).Round(places, mode).Float64()
+	case Decimal:
+		return r.Round(places, mode)
+	case *big.Int:
+		if places >= 0 {
+			return r
+		}
+		return Decimal{r, 0}.Round(places, mode).int()
+	case *big.Rat:
+		// As in Python, rounding a fraction to an integer yields an integer.
+		d := roundRat(r, places, mode)
+		if places <= 0 {
+			return d.int()
+		}
+		return new(big.Rat).SetFrac(d.int(), pow10(d.scale))
+	}
+	e.error("%s requires a number, got %v (%T)", f.function, x, x)
+	return nil
+}
+
+// maxFactorial bounds the argument of factorial, whose result has over
+// 35,000 digits at this limit.
+const maxFactorial = 10000
+
+// factorial(n) returns the product of the integers from 1 to n. Without
+// WithBigNumbers, n is at most 20.
+func builtinFactorial(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	n, ok := demote(args[0]).(int64)
+	if !ok || n < 0 {
+		e.error("factorial requires a non-negative integer, got %v (%T)", args[0], args[0])
+	}
+	if n > maxFactorial {
+		e.error("factorial(%d) is too large", n)
+	}
+
+	r := new(big.Int).MulRange(1, n)
+	if e.config.big {
+		return r
+	}
+	if !r.IsInt64() {
+		e.error("factorial(%d) overflows int64", n)
+	}
+	return r.Int64()
+}
+
+// complexArg returns the number x, the argument of f, as a complex128 and
+// reports whether it was complex to begin with.
+func (e *evaluator) complexArg(f *funcExpr, x interface{}) (c complex128, isComplex bool) {
+	c, ok := toComplex(x)
+	if !ok {
+		e.error("%s requires a number, got %v (%T)", f.function, x, x)
+	}
+	_, isComplex = x.(complex128)
+	return c, isComplex
+}
+
+// real(x) returns the real part of x, which is x itself unless x is complex.
+func builtinReal(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	x := args[0]
+	c, isComplex := e.complexArg(f, x)
+	if isComplex {
+		return real(c)
+	}
+	return x
+}
+
+// imag(x) returns the imaginary part of x, which is zero unless x is
+// complex.
+func builtinImag(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	x := args[0]
+	c, isComplex := e.complexArg(f, x)
+	if isComplex {
+		return imag(c)
+	}
+	return e.convert(int64(0), rank(x))
+}
+
+// conj(x) returns the complex conjugate of x.
+func builtinConj(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	x := args[0]
+	c, isComplex := e.complexArg(f, x)
+	if isComplex {
+		return cmplx.Conj(c)
+	}
+	return x
+}
+
+// arg(x) returns the phase of x in radians, in the range [-Pi, Pi]. The
+// phase of a negative real number is Pi.
+func builtinArg(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	c, _ := e.complexArg(f, args[0])
+	return cmplx.Phase(c)
+}
+
+// sqrt(x) returns the square root of x, which is complex if x is complex or
+// negative.
+func builtinSqrt(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	c, isComplex := e.complexArg(f, args[0])
+	if isComplex || real(c) < 0 {
+		return cmplx.Sqrt(c)
+	}
+	return math.Sqrt(real(c))
+}
+
+// exp(x) returns e raised to the power of x.
+func builtinExp(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	c, isComplex := e.complexArg(f, args[0])
+	if isComplex {
+		return cmplx.Exp(c)
+	}
+	return math.Exp(real(c))
+}
+
+// log(x) returns the natural logarithm of x, which is complex if x is
+// complex or negative.
+func builtinLog(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	c, isComplex := e.complexArg(f, args[0])
+	if isComplex || real(c) < 0 {
+		return cmplx.Log(c)
+	}
+	return math.Log(real(c))
+}
+
+// intCast returns a builtin converting a number to an integer of the given
+// width, which is a uint64 if unsigned and an int64 otherwise. As with a Go
+// conversion, integers are wrapped to the width and fractions truncated.
+func intCast(bits uint, signed bool) builtin {
+	return strict(1, 1, func(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+		u := e.bits(f, args[0])
+		if !signed {
+			return u & (math.MaxUint64 >> (64 - bits))
+		}
+		return int64(u<<(64-bits)) >> (64 - bits)
+	})
+}
+
+var mask64 = new(big.Int).SetUint64(math.MaxUint64)
+
+// bits returns the low 64 bits of the two's complement of x truncated to
+// an integer.
+func (e *evaluator) bits(f *funcExpr, x interface{}) uint64 {
+	switch r := x.(type) {
+	case int64:
+		return uint64(r)
+	case uint64:
+		return r
+	case *big.Int:
+		return new(big.Int).And(r, mask64).Uint64()
+	case float64:
+		if !(r >= -(1<<63) && r < 1<<64) {
+			e.error("%s: %v out of range", f.function, r)
+		}
+		if r < 0 {
+			return uint64(int64(r))
+		}
+		return uint64(r)
+	case Decimal:
+		return e.bits(f, r.Round(0, RoundDown).int())
+	case *big.Rat:
+		return e.bits(f, new(big.Int).Quo(r.Num(), r.Denom()))
+	}
+	e.error("%s requires a real number, got %v (%T)", f.function, x, x)
+	return 0
+}
+
+// coalesce(x, ...) returns the first of its arguments that is not null,
+// evaluating no further, or null if they all are.
+func builtinCoalesce(e *evaluator, f *funcExpr) interface{} {
+	if len(f.args) == 0 {
+		e.error("coalesce takes at least %s, got 0", params(1))
+	}
+	for _, arg := range f.args {
+		if r := e.evaluate(arg); r != Null {
+			return r
+		}
+	}
+	return Null
+}
+
+// ifnull(x, y) returns x, or y if x is null.
+func builtinIfNull(e *evaluator, f *funcExpr) interface{} {
+	if l := len(f.args); l != 2 {
+		e.error("ifnull takes %s, got %d", params(2), l)
+	}
+	if r := e.evaluate(f.args[0]); r != Null {
+		return r
+	}
+	return e.evaluate(f.args[1])
+}
@@ -0,0 +1,42 @@
+package gocalc
+
+import "math/big"
+
+// toComplex converts a number to a complex128.
+func toComplex(v interface{}) (complex128, bool) {
+	switch r := v.(type) {
+	case int64:
+		return complex(float64(r), 0), true
+	case uint64:
+		return complex(float64(r), 0), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(r).Float64()
+		return complex(f, 0), true
+	case float64:
+		return complex(r, 0), true
+	case Decimal:
+		return complex(r.Float64(), 0), true
+	case *big.Rat:
+		f, _ := r.Float64()
+		return complex(f, 0), true
+	case complex128:
+		return r, true
+	}
+	return 0, false
+}
+
+// Complex numbers are unordered, so only arithmetic is defined on them;
+// equality is handled by equal.
+func complexBinary(op *token, l, r complex128) interface{} {
+	switch op.typ {
+	case tokenPlus:
+		return l + r
+	case tokenMinus:
+		return l - r
+	case tokenStar:
+		return l * r
+	case tokenSlash:
+		return l / r
+	}
+	return nil
+}
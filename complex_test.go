@@ -0,0 +1,66 @@
+package gocalc
+
+import "testing"
+
+var complexTests = []expressionTest{
+	{true, "3i", complex(0, 3)},
+	{true, "1 + 2.5i", complex(1, 2.5)},
+	{true, "(1 + 2i) * (3 - 1i)", complex(5, 5)},
+	{true, "(1 + 2i) / 2", complex(0.5, 1)},
+	{true, "1i * 1i", complex(-1, 0)},
+	{true, "-(1 + 1i)", complex(-1, -1)},
+	{true, "1i * 1i = -1", true},
+	{true, "2 + 0i = 2.0", true},
+	{true, "1i != 1", true},
+	{true, "z * 2", complex(2, 4)},
+	{true, "w + 1", complex(2, 1)},
+	{true, "real(3 + 4i)", 3.0},
+	{true, "imag(3 + 4i)", 4.0},
+	{true, "real(7)", 7},
+	{true, "imag(7)", 0},
+	{true, "imag(7.5)", 0.0},
+	{true, "conj(3 + 4i)", complex(3, -4)},
+	{true, "conj(3)", 3},
+	{true, "abs(3 + 4i)", 5.0},
+	{true, "arg(1i) * 2", 3.141592653589793},
+	{true, "arg(-1)", 3.141592653589793},
+	{true, "arg(1)", 0.0},
+	{true, "sqrt(4)", 2.0},
+	{true, "sqrt(-4)", complex(0, 2)},
+	{true, "sqrt(2i)", complex(1, 1)},
+	{true, "exp(0)", 1.0},
+	{true, "exp(0i)", complex(1, 0)},
+	{true, "log(1)", 0.0},
+	{true, "real(log(-1))", 0.0},
+	{true, "imag(log(-1))", 3.141592653589793},
+	{false, "1i < 2i", nil},
+	{false, "1i % 2", nil},
+	{false, "1i | 1", nil},
+	{false, "~1i", nil},
+	{false, "round(1i)", nil},
+	{false, "sqrt(true)", nil},
+	{false, "real()", nil},
+}
+
+func TestComplex(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"z": complex(1, 2),
+		"w": complex64(1 + 1i),
+	})
+
+	for _, test := range complexTests {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestComplexPromotion(t *testing.T) {
+	for _, opt := range []Option{WithDecimal(2, RoundHalfEven), WithBigNumbers(false)} {
+		e, err := NewExpr("1 / 4 + 0.25 + 1i", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := e.Evaluate(nil, nil); err != nil || res != complex(0.5, 1) {
+			t.Errorf("Evaluation returned %v (%T), %v, expected (0.5+1i)", res, res, err)
+		}
+	}
+}
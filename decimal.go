@@ -0,0 +1,330 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// A RoundingMode determines how a Decimal is rounded when digits must be
+// discarded.
+//
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest value, and ties to an even digit.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest value, and ties away from zero.
+	RoundHalfUp
+	// RoundHalfDown rounds to the nearest value, and ties toward zero.
+	RoundHalfDown
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundDown rounds toward zero, truncating.
+	RoundDown
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+)
+
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundHalfEven:
+		return "half-even"
+	case RoundHalfUp:
+		return "half-up"
+	case RoundHalfDown:
+		return "half-down"
+	case RoundUp:
+		return "up"
+	case RoundDown:
+		return "down"
+	case RoundCeiling:
+		return "ceiling"
+	case RoundFloor:
+		return "floor"
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int(m))
+}
+
+// A Decimal is an exact base 10 number, the value of numeric literals and
+// results when an Expression is compiled with WithDecimal. Decimals are
+// immutable, and are safe to copy and share.
+//
+type Decimal struct {
+	coef  *big.Int // unscaled value
+	scale int      // digits after the decimal point, never negative
+}
+
+var bigTen = big.NewInt(10)
+
+// maxExponent bounds the exponent accepted by ParseDecimal, so that a
+// short string cannot describe a Decimal with billions of digits.
+const maxExponent = 100000
+
+// ParseDecimal returns the Decimal represented by s, which is written in
+// decimal with an optional sign, fraction and exponent, like "-12.50" or
+// "1.5e3".
+//
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e < -maxExponent || e > maxExponent {
+			return Decimal{}, fmt.Errorf("Invalid decimal %q", s)
+		}
+		mantissa, exp = s[:i], e
+	}
+
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		scale = len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+	if mantissa == "" || mantissa == "-" || mantissa == "+" || strings.ContainsAny(mantissa[1:], "+-") {
+		return Decimal{}, fmt.Errorf("Invalid decimal %q", s)
+	}
+
+	coef, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("Invalid decimal %q", s)
+	}
+	return newDecimal(coef, scale-exp), nil
+}
+
+// newDecimal returns coef * 10^-scale, taking ownership of coef.
+func newDecimal(coef *big.Int, scale int) Decimal {
+	if scale < 0 {
+		coef.Mul(coef, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{coef, scale}
+}
+
+// decimalFromInt returns i as a Decimal.
+func decimalFromInt(i int64) Decimal {
+	return Decimal{big.NewInt(i), 0}
+}
+
+// decimalFromFloat returns the shortest Decimal that converts back to f.
+func decimalFromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("Cannot represent %v as a decimal", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// rescale returns d's unscaled value at the larger scale s.
+func (d Decimal) rescale(s int) *big.Int {
+	if s == d.scale {
+		return d.int()
+	}
+	return new(big.Int).Mul(d.int(), pow10(s-d.scale))
+}
+
+// align returns the unscaled values of d and x at their common scale.
+func (d Decimal) align(x Decimal) (*big.Int, *big.Int, int) {
+	s := d.scale
+	if x.scale > s {
+		s = x.scale
+	}
+	return d.rescale(s), x.rescale(s), s
+}
+
+// Add returns d + x.
+//
+func (d Decimal) Add(x Decimal) Decimal {
+	a, b, s := d.align(x)
+	return Decimal{new(big.Int).Add(a, b), s}
+}
+
+// Sub returns d - x.
+//
+func (d Decimal) Sub(x Decimal) Decimal {
+	a, b, s := d.align(x)
+	return Decimal{new(big.Int).Sub(a, b), s}
+}
+
+// Mul returns d * x.
+//
+func (d Decimal) Mul(x Decimal) Decimal {
+	return Decimal{new(big.Int).Mul(d.int(), x.int()), d.scale + x.scale}
+}
+
+// Quo returns d / x rounded to places digits after the decimal point using
+// mode, with trailing zeros removed. Quo panics if x is zero.
+//
+func (d Decimal) Quo(x Decimal, places int, mode RoundingMode) Decimal {
+	if x.Sign() == 0 {
+		panic("gocalc: decimal division by zero")
+	}
+
+	// d / x = (a * 10^(places+1)) / b * 10^-(places+1) at a common scale;
+	// the extra digit is enough to round correctly once the remainder is
+	// taken into account.
+	a, b, _ := d.align(x)
+	num := new(big.Int).Mul(a, pow10(places+1))
+	q, r := new(big.Int).QuoRem(num, b, new(big.Int))
+	if r.Sign() != 0 {
+		// Keep the result inexact below the last digit: a sticky digit that
+		// can only push rounding past a tie, never create one.
+		q.Mul(q, bigTen)
+		if (num.Sign() < 0) != (b.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+		return Decimal{q, places + 2}.Round(places, mode).trim()
+	}
+	return Decimal{q, places + 1}.Round(places, mode).trim()
+}
+
+// Rem returns the remainder of d / x truncated toward zero, which has the
+// sign of d. Rem panics if x is zero.
+//
+func (d Decimal) Rem(x Decimal) Decimal {
+	if x.Sign() == 0 {
+		panic("gocalc: decimal division by zero")
+	}
+	a, b, s := d.align(x)
+	return Decimal{new(big.Int).Rem(a, b), s}
+}
+
+// Neg returns -d.
+//
+func (d Decimal) Neg() Decimal {
+	return Decimal{new(big.Int).Neg(d.int()), d.scale}
+}
+
+// Abs returns the absolute value of d.
+//
+func (d Decimal) Abs() Decimal {
+	return Decimal{new(big.Int).Abs(d.int()), d.scale}
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of d.
+//
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// Cmp compares d and x, returning -1 if d < x, 0 if d == x and 1 if d > x.
+//
+func (d Decimal) Cmp(x Decimal) int {
+	a, b, _ := d.align(x)
+	return a.Cmp(b)
+}
+
+// Round returns d rounded to places digits after the decimal point using
+// mode. A negative places rounds to the left of the decimal point.
+//
+func (d Decimal) Round(places int, mode RoundingMode) Decimal {
+	if places >= d.scale {
+		return d
+	}
+
+	div := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(d.int(), div, new(big.Int))
+	if r.Sign() != 0 && roundAway(q, r, div, mode) {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return newDecimal(q, places)
+}
+
+// roundAway reports whether the truncated quotient q, with the non-zero
+// remainder r of a division by div, should be moved away from zero.
+func roundAway(q, r, div *big.Int, mode RoundingMode) bool {
+	switch mode {
+	case RoundUp:
+		return true
+	case RoundDown:
+		return false
+	case RoundCeiling:
+		return r.Sign() > 0
+	case RoundFloor:
+		return r.Sign() < 0
+	}
+
+	half := new(big.Int).Abs(r)
+	switch half.Lsh(half, 1).Cmp(div) {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	switch mode {
+	case RoundHalfUp:
+		return true
+	case RoundHalfDown:
+		return false
+	}
+	return q.Bit(0) == 1
+}
+
+// trim removes trailing zeros after the decimal point.
+func (d Decimal) trim() Decimal {
+	if d.scale == 0 || d.Sign() == 0 {
+		return Decimal{d.int(), 0}
+	}
+	q, r := new(big.Int), new(big.Int)
+	c, s := d.int(), d.scale
+	for s > 0 {
+		q.QuoRem(c, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		c, q = q, new(big.Int)
+		s--
+	}
+	return Decimal{c, s}
+}
+
+// IsInt reports whether d has no fractional part.
+//
+func (d Decimal) IsInt() bool {
+	return d.trim().scale == 0
+}
+
+// Float64 returns the float64 nearest to d.
+//
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(d.int(), pow10(d.scale)).Float64()
+	return f
+}
+
+// String returns d in decimal notation with all of its digits, like
+// "-12.50".
+//
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	i := len(digits) - d.scale
+	return sign + digits[:i] + "." + digits[i:]
+}
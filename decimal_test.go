@@ -0,0 +1,201 @@
+package gocalc
+
+import (
+	"fmt"
+	"testing"
+)
+
+var decimalParseTests = []struct {
+	ok     bool
+	in     string
+	expect string
+}{
+	{true, "0", "0"},
+	{true, "12.50", "12.50"},
+	{true, "-0.05", "-0.05"},
+	{true, "+3", "3"},
+	{true, ".5", "0.5"},
+	{true, "5.", "5"},
+	{true, "1.5e3", "1500"},
+	{true, "1.5E-3", "0.0015"},
+	{true, "-12e-1", "-1.2"},
+	{false, "", ""},
+	{false, ".", ""},
+	{false, "-", ""},
+	{false, "1.2.3", ""},
+	{false, "1e", ""},
+	{false, "1-2", ""},
+	{false, "1e999999999", ""},
+	{false, "abc", ""},
+}
+
+func TestParseDecimal(t *testing.T) {
+	for _, test := range decimalParseTests {
+		d, err := ParseDecimal(test.in)
+		if test.ok && err != nil {
+			t.Errorf("ParseDecimal(%q) failed: %v", test.in, err)
+		} else if test.ok && d.String() != test.expect {
+			t.Errorf("ParseDecimal(%q) = %s, expected %s", test.in, d, test.expect)
+		} else if !test.ok && err == nil {
+			t.Errorf("ParseDecimal(%q) = %s, expected an error", test.in, d)
+		}
+	}
+}
+
+func dec(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+var decimalRoundTests = []struct {
+	in     string
+	places int
+	mode   RoundingMode
+	expect string
+}{
+	{"2.5", 0, RoundHalfEven, "2"},
+	{"3.5", 0, RoundHalfEven, "4"},
+	{"-2.5", 0, RoundHalfEven, "-2"},
+	{"2.5", 0, RoundHalfUp, "3"},
+	{"-2.5", 0, RoundHalfUp, "-3"},
+	{"2.5", 0, RoundHalfDown, "2"},
+	{"2.51", 0, RoundHalfDown, "3"},
+	{"2.1", 0, RoundUp, "3"},
+	{"-2.1", 0, RoundUp, "-3"},
+	{"2.9", 0, RoundDown, "2"},
+	{"-2.9", 0, RoundDown, "-2"},
+	{"-2.1", 0, RoundCeiling, "-2"},
+	{"2.1", 0, RoundCeiling, "3"},
+	{"-2.1", 0, RoundFloor, "-3"},
+	{"2.675", 2, RoundHalfUp, "2.68"},
+	{"2.665", 2, RoundHalfEven, "2.66"},
+	{"1.2", 3, RoundHalfEven, "1.2"},
+	{"1250", -2, RoundHalfEven, "1200"},
+	{"1350", -2, RoundHalfEven, "1400"},
+}
+
+func TestDecimalRound(t *testing.T) {
+	for _, test := range decimalRoundTests {
+		if r := dec(test.in).Round(test.places, test.mode); r.String() != test.expect {
+			t.Errorf("%s.Round(%d, %s) = %s, expected %s", test.in, test.places, test.mode, r, test.expect)
+		}
+	}
+}
+
+var decimalQuoTests = []struct {
+	x, y   string
+	places int
+	mode   RoundingMode
+	expect string
+}{
+	{"1", "3", 4, RoundHalfEven, "0.3333"},
+	{"2", "3", 4, RoundHalfEven, "0.6667"},
+	{"-2", "3", 4, RoundDown, "-0.6666"},
+	{"10", "4", 10, RoundHalfEven, "2.5"},
+	{"1", "8", 2, RoundHalfEven, "0.12"},
+	{"1", "8", 2, RoundHalfUp, "0.13"},
+	{"1.0001", "8", 2, RoundHalfEven, "0.13"},
+	{"-1", "8", 2, RoundHalfUp, "-0.13"},
+	{"1", "-8", 2, RoundFloor, "-0.13"},
+	{"0.3", "0.1", 2, RoundHalfEven, "3"},
+	{"100", "0.03", 0, RoundHalfEven, "3333"},
+}
+
+func TestDecimalQuo(t *testing.T) {
+	for _, test := range decimalQuoTests {
+		if r := dec(test.x).Quo(dec(test.y), test.places, test.mode); r.String() != test.expect {
+			t.Errorf("%s / %s (%d, %s) = %s, expected %s", test.x, test.y, test.places, test.mode, r, test.expect)
+		}
+	}
+}
+
+var decimalExpressionTests = []struct {
+	ok     bool
+	expr   string
+	places int
+	mode   RoundingMode
+	expect interface{}
+}{
+	{true, "0.1 + 0.2 = 0.3", 10, RoundHalfEven, true},
+	{true, "0.1 + 0.2", 10, RoundHalfEven, dec("0.3")},
+	{true, "price * qty * (1 - discount)", 2, RoundHalfEven, dec("26.973")},
+	{true, "round(price * qty * (1 - discount), 2)", 2, RoundHalfEven, dec("26.97")},
+	{true, "round(2.675, 2)", 2, RoundHalfUp, dec("2.68")},
+	{true, "round(2.5)", 2, RoundHalfEven, dec("2")},
+	{true, "round(2.5)", 2, RoundHalfUp, dec("3")},
+	{true, "1 / 3", 6, RoundHalfEven, dec("0.333333")},
+	{true, "2 / 3", 2, RoundDown, dec("0.66")},
+	{true, "6 / 3", 2, RoundDown, dec("2")},
+	{true, "7 % 3", 2, RoundDown, 1},
+	{true, "10.5 % 3", 2, RoundDown, dec("1.5")},
+	{true, "-1.25 / 2", 2, RoundHalfEven, dec("-0.62")},
+	{true, "rate + 1", 2, RoundHalfEven, dec("1.1")},
+	{true, "floor(-1.5) + ceil(1.2)", 2, RoundHalfEven, dec("0")},
+	{true, "abs(-0.10)", 2, RoundHalfEven, dec("0.10")},
+	{true, "1.10 > 1.1", 2, RoundHalfEven, false},
+	{true, "1.10 >= 1", 2, RoundHalfEven, true},
+	{true, "-1.5 < 0.5", 2, RoundHalfEven, true},
+	{false, "1.5 / 0", 2, RoundHalfEven, nil},
+	{false, "1 / 0", 2, RoundHalfEven, nil},
+	{false, "1.5 % 0.0", 2, RoundHalfEven, nil},
+	{false, "1.5 | 1", 2, RoundHalfEven, nil},
+}
+
+func TestDecimalExpression(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"price":    dec("9.99"),
+		"qty":      3,
+		"discount": 0.1,
+		"rate":     0.1,
+	})
+
+	for _, test := range decimalExpressionTests {
+		checkEvaluation(t, expressionTest{test.ok, test.expr, test.expect}, params, nil, WithDecimal(test.places, test.mode))
+	}
+}
+
+func TestDecimalPlacesOption(t *testing.T) {
+	for _, places := range []int{-1, maxPlaces + 1, 100000000} {
+		diags := Check("1 / 3", WithDecimal(places, RoundHalfEven))
+		if len(diags) != 1 || diags[0].Code != CodeBadOption {
+			t.Errorf("WithDecimal(%d): Check returned %v, expected one %s diagnostic", places, diags, CodeBadOption)
+		}
+	}
+	// Parse errors, even one at the start of the input, are reported too.
+	for _, expr := range []string{")", "+"} {
+		diags := Check(expr, WithDecimal(-1, RoundHalfEven))
+		codes := map[string]bool{}
+		for _, d := range diags {
+			codes[d.Code] = true
+		}
+		if len(diags) != 2 || !codes[CodeUnexpectedToken] || !codes[CodeBadOption] {
+			t.Errorf("WithDecimal(-1): Check of \"%s\" returned %v, expected %s and %s diagnostics", expr, diags, CodeUnexpectedToken, CodeBadOption)
+		}
+	}
+
+	e, err := NewExpr("1 / 3", WithDecimal(maxPlaces, RoundHalfEven))
+	if err != nil {
+		t.Fatalf("WithDecimal(%d): Cannot test; lexer or parser error: %v", maxPlaces, err)
+	}
+	if res, err := e.Evaluate(nil, nil); err != nil || len(fmt.Sprint(res)) != maxPlaces+2 {
+		t.Errorf("WithDecimal(%d): 1 / 3 returned %v, %v", maxPlaces, res, err)
+	}
+}
+
+func ExampleWithDecimal() {
+	expression, _ := NewExpr("price * qty * (1 - discount)", WithDecimal(2, RoundHalfEven))
+
+	result, _ := expression.Evaluate(MapEnv(map[string]interface{}{
+		"price":    0.10,
+		"qty":      3,
+		"discount": 0.2,
+	}), nil)
+
+	fmt.Println(result.(Decimal).String())
+
+	// Output:
+	// 0.24
+}
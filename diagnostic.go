@@ -0,0 +1,109 @@
+package gocalc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Severity indicates how serious a Diagnostic is.
+//
+type Severity int
+
+const (
+	// SeverityError marks a problem that prevents compilation.
+	SeverityError Severity = iota
+	// SeverityWarning marks a suspicious construct that still compiles.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic codes identify the kind of problem a Diagnostic describes.
+//
+const (
+	CodeLexical         = "lexical"          // malformed token
+	CodeBadLiteral      = "bad-literal"      // invalid or out of range literal
+	CodeUnexpectedToken = "unexpected-token" // token cannot appear here
+	CodeUnclosedParen   = "unclosed-paren"   // missing right paren
+	CodeUnclosedBracket = "unclosed-bracket" // missing right bracket
+	CodeUnclosedBrace   = "unclosed-brace"   // missing right brace
+	CodeDuplicateKey    = "duplicate-key"    // key repeated in a map literal
+	CodeDuplicateParam  = "duplicate-param"  // parameter repeated in a lambda or let
+	CodeExpectedEOF     = "expected-eof"     // trailing input after the expression
+	CodeExpectedEnd     = "expected-end"     // statement not ended by a semicolon or newline
+	CodeSingleEqual     = "single-equal"     // "=" used for equality in strict syntax
+	CodeMixedComparison = "mixed-comparison" // comparison compared for equality without parentheses
+	CodeUnitMismatch    = "unit-mismatch"    // quantities of different dimensions added or compared
+	CodeBadOption       = "bad-option"       // option given an invalid value
+	CodeMathCaret       = "math-caret"       // "^", which is exclusive or, used in math notation
+)
+
+// A Span is the half-open byte range [Start, End) of the source that a
+// Diagnostic refers to.
+//
+type Span struct {
+	Start int
+	End   int
+}
+
+// A Diagnostic describes a single problem found while compiling an
+// expression.
+//
+type Diagnostic struct {
+	Severity Severity
+	Span     Span
+	Message  string
+	Code     string
+}
+
+// Error is Diagnostic's implementation of the error interface.
+//
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s at %d: %s", d.Severity, d.Span.Start, d.Message)
+}
+
+// Diagnostics is the error returned by NewExpr when compilation fails. It
+// holds every problem the parser found, in source order.
+//
+type Diagnostics []Diagnostic
+
+// Error is Diagnostics' implementation of the error interface.
+//
+func (d Diagnostics) Error() string {
+	var b bytes.Buffer
+	for i, diag := range d {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(diag.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns each Diagnostic as an error, for use with errors.Is and
+// errors.As.
+//
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, len(d))
+	for i, diag := range d {
+		errs[i] = diag
+	}
+	return errs
+}
+
+func (d Diagnostics) hasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package gocalc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Associativity is how a sequence of binary operators of equal precedence
+// groups.
+//
+type Associativity int
+
+const (
+	// LeftAssociative operators group from the left, so that a - b - c is
+	// (a - b) - c. All of the built-in binary operators are left
+	// associative.
+	LeftAssociative Associativity = iota
+
+	// RightAssociative operators group from the right, so that a ?? b ?? c
+	// is a ?? (b ?? c).
+	RightAssociative
+)
+
+// A BinaryOperator is an infix operator that a Dialect adds to the built-in
+// ones.
+//
+// Precedence places the operator among the built-in binary operators, which
+// from loosest to tightest are:
+//
+//	0  || or
+//	1  && and
+//	2  |
+//	3  ^
+//	4  &
+//	5  == = != is
+//	6  < <= > >= in not in between
+//	7  << >>
+//	8  + -
+//	9  * / %
+//
+// An operator with the precedence of a built-in one binds exactly as
+// tightly, and groups with it according to its own Associativity.
+//
+type BinaryOperator struct {
+	Symbol        string
+	Precedence    int
+	Associativity Associativity
+
+	// Eval computes the result of the operator. Unlike the built-in
+	// operators, it is called with Null operands. The result is converted
+	// like the values returned by a FuncHandler, and a non-nil error fails
+	// the evaluation.
+	Eval func(x, y interface{}) (interface{}, error)
+}
+
+// A UnaryOperator is a prefix operator that a Dialect adds to the built-in
+// ones.
+//
+// Its operand extends over the binary operators whose precedence is at least
+// Precedence, so an operator with the precedence of the built-in unary
+// operators, 10, applies to the operand that follows it only, while one with
+// a precedence of 8 applies to "x + y" in "op x + y < z".
+//
+type UnaryOperator struct {
+	Symbol     string
+	Precedence int
+
+	// Eval computes the result of the operator, like BinaryOperator's Eval.
+	Eval func(x interface{}) (interface{}, error)
+}
+
+// A Dialect is a set of operators that an Expression compiled WithDialect
+// may use in addition to the built-in ones, which are part of every Dialect.
+// A new Dialect, like a nil one, is the default dialect, and has the
+// built-in operators only.
+//
+// A symbol is either a word, which lexes like an identifier and is then no
+// longer available as one, or a sequence of punctuation and symbol
+// characters other than brackets, braces, quotes, commas, semicolons, dots
+// and underscores. A symbol cannot be a built-in operator or keyword, but it
+// may begin like one, as "~=" and "**" do, and then takes precedence over it
+// wherever it appears outside of a string.
+//
+// A Dialect must not be changed while expressions are being compiled with
+// it; expressions that were compiled before a change are not affected.
+//
+type Dialect struct {
+	symbols []*operator          // the punctuation symbols, longest first
+	words   map[string]*operator // the word symbols
+}
+
+// An operator is the binary and unary operators written with one symbol.
+type operator struct {
+	symbol string
+	binary *BinaryOperator
+	unary  *UnaryOperator
+}
+
+// NewDialect returns a Dialect with no operators beyond the built-in ones.
+//
+func NewDialect() *Dialect {
+	return &Dialect{words: map[string]*operator{}}
+}
+
+// AddBinary adds the binary operator op, or returns an error if op is
+// invalid or its symbol is already a binary operator.
+//
+func (d *Dialect) AddBinary(op BinaryOperator) error {
+	if op.Eval == nil {
+		return fmt.Errorf("Operator %q has no Eval", op.Symbol)
+	}
+	if op.Precedence < 0 || op.Precedence > 9 {
+		return fmt.Errorf("Operator %q has precedence %d, which is not between 0 and 9", op.Symbol, op.Precedence)
+	}
+	if op.Associativity != LeftAssociative && op.Associativity != RightAssociative {
+		return fmt.Errorf("Operator %q has invalid associativity %d", op.Symbol, op.Associativity)
+	}
+	o, err := d.operator(op.Symbol)
+	if err != nil {
+		return err
+	}
+	if o.binary != nil {
+		return fmt.Errorf("Operator %q is already a binary operator", op.Symbol)
+	}
+	o.binary = &op
+	d.add(o)
+	return nil
+}
+
+// AddUnary adds the unary operator op, or returns an error if op is invalid
+// or its symbol is already a unary operator.
+//
+func (d *Dialect) AddUnary(op UnaryOperator) error {
+	if op.Eval == nil {
+		return fmt.Errorf("Operator %q has no Eval", op.Symbol)
+	}
+	if op.Precedence < 0 || op.Precedence > 10 {
+		return fmt.Errorf("Operator %q has precedence %d, which is not between 0 and 10", op.Symbol, op.Precedence)
+	}
+	o, err := d.operator(op.Symbol)
+	if err != nil {
+		return err
+	}
+	if o.unary != nil {
+		return fmt.Errorf("Operator %q is already a unary operator", op.Symbol)
+	}
+	o.unary = &op
+	d.add(o)
+	return nil
+}
+
+// operator returns a copy of the operator written as symbol, which is new if
+// there is none, so that tokens lexed before a change keep their operator.
+func (d *Dialect) operator(symbol string) (*operator, error) {
+	if err := validSymbol(symbol); err != nil {
+		return nil, err
+	}
+	o := &operator{symbol: symbol}
+	if old := d.lookup(symbol); old != nil {
+		*o = *old
+	}
+	return o, nil
+}
+
+// add adds the operator o, replacing any with the same symbol.
+func (d *Dialect) add(o *operator) {
+	if isIdentifier(o.symbol) {
+		d.words[o.symbol] = o
+		return
+	}
+	for i, s := range d.symbols {
+		if s.symbol == o.symbol {
+			d.symbols[i] = o
+			return
+		}
+	}
+	d.symbols = append(d.symbols, o)
+	sort.SliceStable(d.symbols, func(i, j int) bool {
+		return len(d.symbols[i].symbol) > len(d.symbols[j].symbol)
+	})
+}
+
+// lookup returns the operator written as symbol, or nil.
+func (d *Dialect) lookup(symbol string) *operator {
+	if d == nil {
+		return nil
+	}
+	if o, ok := d.words[symbol]; ok {
+		return o
+	}
+	for _, o := range d.symbols {
+		if o.symbol == symbol {
+			return o
+		}
+	}
+	return nil
+}
+
+// prefix returns the operator with the longest punctuation symbol that s
+// begins with, or nil.
+func (d *Dialect) prefix(s string) *operator {
+	if d == nil {
+		return nil
+	}
+	for _, o := range d.symbols {
+		if strings.HasPrefix(s, o.symbol) {
+			return o
+		}
+	}
+	return nil
+}
+
+// validSymbol returns an error if symbol cannot be used as an operator.
+func validSymbol(symbol string) error {
+	if isIdentifier(symbol) {
+		return nil
+	}
+	if symbol == "" {
+		return fmt.Errorf("Operator has no symbol")
+	}
+	if _, ok := keywords[symbol]; ok {
+		return fmt.Errorf("Operator %q is a keyword", symbol)
+	}
+	for _, r := range symbol {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) || strings.ContainsRune(`()[]{}"',;._`, r) {
+			return fmt.Errorf("Operator %q cannot contain %q", symbol, r)
+		}
+	}
+	if t := newLexer(symbol, nil).token(); t.typ != tokenError && t.end == len(symbol) {
+		return fmt.Errorf("Operator %q is already an operator", symbol)
+	}
+	return nil
+}
+
+// binaryOperator applies the dialect's binary operator op to left and right.
+func (e *evaluator) binaryOperator(op *token, left, right interface{}) interface{} {
+	r, err := op.op.binary.Eval(left, right)
+	return e.operatorResult(op, r, err)
+}
+
+// unaryOperator applies the dialect's unary operator op to operand.
+func (e *evaluator) unaryOperator(op *token, operand interface{}) interface{} {
+	r, err := op.op.unary.Eval(operand)
+	return e.operatorResult(op, r, err)
+}
+
+func (e *evaluator) operatorResult(op *token, r interface{}, err error) interface{} {
+	if err != nil {
+		e.error("Operator %s: %s", op, err)
+	}
+	if r = e.normalize(r); r == nil {
+		e.error("Operator %s returned no value", op)
+	}
+	return r
+}
@@ -0,0 +1,232 @@
+package gocalc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func testDialect(t testing.TB) *Dialect {
+	float := func(x interface{}) (float64, error) {
+		switch n := x.(type) {
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+		return 0, fmt.Errorf("%v is not a number", x)
+	}
+	d := NewDialect()
+	for _, op := range []BinaryOperator{
+		{"~=", 5, LeftAssociative, func(x, y interface{}) (interface{}, error) {
+			a, err := float(x)
+			if err != nil {
+				return nil, err
+			}
+			b, err := float(y)
+			if err != nil {
+				return nil, err
+			}
+			return math.Abs(a-b) < 1e-9, nil
+		}},
+		{"??", 0, RightAssociative, func(x, y interface{}) (interface{}, error) {
+			if x == Null {
+				return y, nil
+			}
+			return x, nil
+		}},
+		{"**", 9, RightAssociative, func(x, y interface{}) (interface{}, error) {
+			a, err := float(x)
+			if err != nil {
+				return nil, err
+			}
+			b, err := float(y)
+			if err != nil {
+				return nil, err
+			}
+			return math.Pow(a, b), nil
+		}},
+		{"mod", 9, LeftAssociative, func(x, y interface{}) (interface{}, error) {
+			a, aok := x.(int64)
+			b, bok := y.(int64)
+			if !aok || !bok || b == 0 {
+				return nil, errors.New("invalid operands")
+			}
+			return (a%b + b) % b, nil
+		}},
+		{"≠", 5, LeftAssociative, func(x, y interface{}) (interface{}, error) {
+			return x != y, nil
+		}},
+		{"<>", 5, LeftAssociative, func(x, y interface{}) (interface{}, error) {
+			return nil, nil
+		}},
+	} {
+		if err := d.AddBinary(op); err != nil {
+			t.Fatalf("AddBinary(%q): %v", op.Symbol, err)
+		}
+	}
+	for _, op := range []UnaryOperator{
+		{"√", 10, func(x interface{}) (interface{}, error) {
+			a, err := float(x)
+			return math.Sqrt(a), err
+		}},
+		{"?", 8, func(x interface{}) (interface{}, error) {
+			return x != Null, nil
+		}},
+		{"**", 10, func(x interface{}) (interface{}, error) {
+			return x, nil
+		}},
+		{"¬", 5, func(x interface{}) (interface{}, error) {
+			return x == false, nil
+		}},
+	} {
+		if err := d.AddUnary(op); err != nil {
+			t.Fatalf("AddUnary(%q): %v", op.Symbol, err)
+		}
+	}
+	return d
+}
+
+func TestDialect(t *testing.T) {
+	d := testDialect(t)
+	params := MapEnv(map[string]interface{}{
+		"a":   0.1 + 0.2,
+		"n":   Null,
+		"x":   4,
+		"mod": 1,
+	})
+
+	for _, test := range []expressionTest{
+		{true, "a ~= 0.3", true},
+		{true, "a~=0.3 && 1 ~= 2 - 1", true},
+		{true, "a ~= 0.4", false},
+		{true, "n ?? 2", int64(2)},
+		{true, "n ?? n ?? 3", int64(3)},
+		{true, "1 ?? n", int64(1)},
+		{true, "n ?? 1 + 1", int64(2)},
+		{true, "2 ** 3 ** 2", 512.0},
+		{true, "2 * 3 ** 2", 36.0},
+		{true, "**x + 1", int64(5)},
+		{true, "-7 mod 3", int64(2)},
+		{true, "x mod 3 * 2", int64(2)},
+		{true, "√x + √(x * 4)", 6.0},
+		{true, "- √x", -2.0},
+		{true, "1 ≠ 2", true},
+		{true, "? n + 1", false},
+		{true, "? x + 1 && true", true},
+		{true, `"a ?? b" ?? 1`, "a ?? b"},
+		{true, "¬x == 4", false},
+		{true, "(¬x) == false", true},
+		{false, "1 <> 2", nil},
+		{false, "x mod 0", nil},
+		{false, "√\"a\"", nil},
+		{false, "x ??", nil},
+		{false, "?? x", nil},
+		{false, "mod(x, 2)", nil},
+	} {
+		checkEvaluation(t, test, params, nil, WithDialect(d))
+	}
+
+	for _, src := range []string{"a ~= 0.3", "n ?? 2", "7 mod 3", "√x"} {
+		if _, err := NewExpr(src); err == nil {
+			t.Errorf("Expression \"%s\" compiled without WithDialect", src)
+		}
+	}
+	if e, err := NewExpr("1 + mod", WithDialect(NewDialect())); err != nil {
+		t.Errorf("Expression \"1 + mod\" in the default dialect: %v", err)
+	} else if res, err := e.Evaluate(params, nil); err != nil || res != int64(2) {
+		t.Errorf("Expression \"1 + mod\" in the default dialect returned %v, %v; expected 2", res, err)
+	}
+}
+
+func TestDialectPrinter(t *testing.T) {
+	d := testDialect(t)
+	for _, test := range []struct {
+		expr    string
+		printed string
+	}{
+		{"a??b??c", "a ?? b ?? c"},
+		{"(a ?? b) ?? c", "(a ?? b) ?? c"},
+		{"(a ?? b) || c", "(a ?? b) || c"},
+		{"a || b ?? c", "a || b ?? c"},
+		{"a ?? (b || c)", "a ?? b || c"},
+		{"(2 ** 3) ** 2", "(2 ** 3) ** 2"},
+		{"2 * 3 ** 2", "2 * 3 ** 2"},
+		{"2 * (3 ** 2)", "2 * (3 ** 2)"},
+		{"x mod(3)", "x mod 3"},
+		{"√√x", "√ √x"},
+		{"-√x + **-x", "- √x + ** -x"},
+		{"!!x", "! !x"},
+		{"?(x + 1)", "?x + 1"},
+		{"(?x) + 1", "(?x) + 1"},
+		{"?x < 1", "?x < 1"},
+		{"(?x) < 1 < 2", "?x < 1 < 2"},
+		{"(¬x) == y", "(¬x) == y"},
+		{"¬x == y", "¬x == y"},
+		{"¬(x < 1 < 2)", "¬x < 1 < 2"},
+		{"(¬x) is null", "(¬x) is null"},
+		{"(¬x) between 1 and 2", "(¬x) between 1 and 2"},
+		{"(¬x) ?? y", "¬x ?? y"},
+		{"a ~= b < c", "a ~= b < c"},
+	} {
+		e, err := NewExpr(test.expr, WithDialect(d))
+		if err != nil {
+			t.Errorf("Expression \"%s\": Cannot test; lexer or parser error: %v", test.expr, err)
+			continue
+		}
+		if s := e.String(); s != test.printed {
+			t.Errorf("Expression \"%s\": printed as %q, expected %q", test.expr, s, test.printed)
+			continue
+		}
+		printed, err := NewExpr(e.String(), WithDialect(d))
+		if err != nil {
+			t.Errorf("Expression \"%s\": printed %q, which does not compile: %v", test.expr, e, err)
+		} else if dump(printed) != dump(e) {
+			t.Errorf("Expression \"%s\": printed %q, which compiles to a different tree", test.expr, e)
+		}
+	}
+}
+
+func TestDialectAdd(t *testing.T) {
+	eval := func(x, y interface{}) (interface{}, error) { return x, nil }
+	for _, op := range []BinaryOperator{
+		{"", 5, LeftAssociative, eval},
+		{"==", 5, LeftAssociative, eval},
+		{"=>", 5, LeftAssociative, eval},
+		{"in", 5, LeftAssociative, eval},
+		{"(", 5, LeftAssociative, eval},
+		{"a+", 5, LeftAssociative, eval},
+		{"+ +", 5, LeftAssociative, eval},
+		{"._", 5, LeftAssociative, eval},
+		{"~=", -1, LeftAssociative, eval},
+		{"~=", 10, LeftAssociative, eval},
+		{"~=", 5, Associativity(2), eval},
+		{"~=", 5, LeftAssociative, nil},
+	} {
+		if err := NewDialect().AddBinary(op); err == nil {
+			t.Errorf("AddBinary(%q, %d) passed but should have failed", op.Symbol, op.Precedence)
+		}
+	}
+
+	d := NewDialect()
+	if err := d.AddBinary(BinaryOperator{"~=", 5, LeftAssociative, eval}); err != nil {
+		t.Fatalf("AddBinary: %v", err)
+	}
+	if err := d.AddBinary(BinaryOperator{"~=", 6, LeftAssociative, eval}); err == nil {
+		t.Errorf("AddBinary of \"~=\" twice passed but should have failed")
+	}
+	e, err := NewExpr("1 ~= 2", WithDialect(d))
+	if err != nil {
+		t.Fatalf("Cannot test; lexer or parser error: %v", err)
+	}
+	if err := d.AddUnary(UnaryOperator{"~=", 10, func(x interface{}) (interface{}, error) { return x, nil }}); err != nil {
+		t.Fatalf("AddUnary: %v", err)
+	}
+	if err := d.AddUnary(UnaryOperator{"!", 10, func(x interface{}) (interface{}, error) { return x, nil }}); err == nil {
+		t.Errorf("AddUnary of \"!\" passed but should have failed")
+	}
+	if res, err := e.Evaluate(nil, nil); err != nil || res != int64(1) {
+		t.Errorf("Expression \"1 ~= 2\" returned %v, %v after changing its dialect", res, err)
+	}
+}
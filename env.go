@@ -0,0 +1,68 @@
+package gocalc
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MapEnv returns a ParamResolver that looks identifiers up in m. As with any
+// resolved value, numbers of any Go type are converted to int64 or float64
+// during evaluation.
+//
+func MapEnv(m map[string]interface{}) ParamResolver {
+	return func(name string) interface{} {
+		return m[name]
+	}
+}
+
+// StructEnv returns a ParamResolver that resolves identifiers to the exported
+// fields of v, which must be a struct or a pointer to one. A field tagged
+// `calc:"name"` is resolved as name, and one tagged `calc:"-"` is hidden.
+// If v is not a struct, resolving any identifier with the returned resolver
+// fails the evaluation with an EvaluationError.
+//
+func StructEnv(v interface{}) ParamResolver {
+	rv := reflect.ValueOf(v)
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		err := EvaluationError(fmt.Sprintf("StructEnv requires a struct, got %T", v))
+		return func(name string) interface{} {
+			panic(err)
+		}
+	}
+
+	return func(name string) interface{} {
+		// Look through pointers on every call, so that changes to a struct
+		// passed by pointer are seen by later evaluations.
+		s := indirect(rv)
+		if !s.IsValid() {
+			return nil
+		}
+		f, ok := structField(s, name)
+		if !ok || !f.CanInterface() {
+			return nil
+		}
+		return f.Interface()
+	}
+}
+
+// ChainEnv returns a ParamResolver that tries each of envs in order, and
+// resolves an identifier with the first one that knows it. Earlier envs
+// therefore shadow later ones, which allows scopes to be layered.
+//
+func ChainEnv(envs ...ParamResolver) ParamResolver {
+	return func(name string) interface{} {
+		for _, env := range envs {
+			if env == nil {
+				continue
+			}
+			if v := env(name); v != nil {
+				return v
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package gocalc
+
+import (
+	"strings"
+	"testing"
+)
+
+type envStruct struct {
+	Rate     float32 `calc:"rate"`
+	Qty      uint16
+	Discount int8 `calc:"discount"`
+	Hidden   int  `calc:"-"`
+	private  int
+}
+
+var envTests = []struct {
+	ok     bool
+	expr   string
+	env    ParamResolver
+	expect interface{}
+}{
+	{true, "a + b + c + d", MapEnv(map[string]interface{}{"a": int8(1), "b": uint32(2), "c": int32(3), "d": uint64(4)}), int64(10)},
+	{true, "x * 2", MapEnv(map[string]interface{}{"x": float32(1.5)}), 3.0},
+	{true, "n.total", MapEnv(map[string]interface{}{"n": map[string]interface{}{"total": uint8(7)}}), int64(7)},
+	{false, "y", MapEnv(map[string]interface{}{"x": 1}), nil},
+	{false, "x", MapEnv(nil), nil},
+
+	{true, "rate * Qty - discount", StructEnv(envStruct{Rate: 0.5, Qty: 4, Discount: 1}), 1.0},
+	{true, "Qty", StructEnv(&envStruct{Qty: 3}), int64(3)},
+	{false, "Hidden", StructEnv(envStruct{Hidden: 1}), nil},
+	{false, "private", StructEnv(envStruct{private: 1}), nil},
+	{false, "Rate", StructEnv(envStruct{}), nil},
+	{false, "rate", StructEnv((*envStruct)(nil)), nil},
+
+	{true, "x + y", ChainEnv(MapEnv(map[string]interface{}{"x": 1}), MapEnv(map[string]interface{}{"x": 10, "y": 2})), int64(3)},
+	{true, "Qty + y", ChainEnv(nil, StructEnv(envStruct{Qty: 5}), MapEnv(map[string]interface{}{"y": 2})), int64(7)},
+	{false, "z", ChainEnv(MapEnv(map[string]interface{}{"x": 1})), nil},
+	{false, "z", ChainEnv(), nil},
+}
+
+func TestEnv(t *testing.T) {
+	for _, test := range envTests {
+		checkEvaluation(t, expressionTest{test.ok, test.expr, test.expect}, test.env, nil)
+	}
+}
+
+func TestStructEnvOfNonStruct(t *testing.T) {
+	e, err := NewExpr("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.Evaluate(StructEnv(map[string]interface{}{"x": 1}), nil)
+	if err == nil || !strings.Contains(err.Error(), "StructEnv requires a struct") {
+		t.Errorf("Evaluation with a StructEnv of a map returned %v, expected a StructEnv error", err)
+	}
+}
@@ -1,15 +1,24 @@
 package gocalc
 
-import "fmt"
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
 
 type evaluator struct {
 	result        interface{}
+	config        *config
 	paramResolver ParamResolver
 	funcHandler   FuncHandler
+	scope         *scope    // names bound by enclosing lambdas and lets
+	depth         int       // number of nested lambda calls
+	time          time.Time // the result of now(), once it has been called
 }
 
-func newEvaluator(p ParamResolver, f FuncHandler) *evaluator {
+func newEvaluator(c *config, p ParamResolver, f FuncHandler) *evaluator {
 	return &evaluator{
+		config:        c,
 		paramResolver: p,
 		funcHandler:   f,
 	}
@@ -35,239 +44,27 @@ func (e *evaluator) error(format string, args ...interface{}) {
 }
 
 func (e *evaluator) visitBinaryExpr(b *binaryExpr) {
-	b.left.accept(e)
-	left := e.result
-
-	b.right.accept(e)
-	right := e.result
-
-	e.result = nil
+	left := e.evaluate(b.left)
+	right := e.evaluate(b.right)
+	e.result = e.binary(b.op, left, right)
+}
 
-	switch b.op.typ {
-	case tokenLogicalOr:
-		switch l := left.(type) {
-		case bool:
-			switch r := right.(type) {
-			case bool:
-				e.result = l || r
-			}
-		}
-	case tokenLogicalAnd:
-		switch l := left.(type) {
-		case bool:
-			switch r := right.(type) {
-			case bool:
-				e.result = l && r
-			}
-		}
-	case tokenBitwiseOr:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l | r
-			}
-		}
-	case tokenBitwiseAnd:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l & r
-			}
-		}
-	case tokenBitwiseXor:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l ^ r
-			}
-		}
-	case tokenEqual:
-		switch l := left.(type) {
-		default:
-			switch r := right.(type) {
-			default:
-				e.result = l == r
-			}
-		}
-	case tokenNotEqual:
-		switch l := left.(type) {
-		default:
-			switch r := right.(type) {
-			default:
-				e.result = l != r
-			}
-		}
-	case tokenLessThan:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l < r
-			case float64:
-				e.result = float64(l) < r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l < r
-			case int64:
-				e.result = l < float64(r)
-			}
-		}
-	case tokenLessOrEqual:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l <= r
-			case float64:
-				e.result = float64(l) <= r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l <= r
-			case int64:
-				e.result = l <= float64(r)
-			}
-		}
-	case tokenGreaterThan:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l > r
-			case float64:
-				e.result = float64(l) > r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l > r
-			case int64:
-				e.result = l > float64(r)
-			}
-		}
-	case tokenGreaterOrEqual:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l >= r
-			case float64:
-				e.result = float64(l) >= r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l >= r
-			case int64:
-				e.result = l >= float64(r)
-			}
-		}
-	case tokenLeftShift:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l << uint64(r)
-			}
-		}
-	case tokenRightShift:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l >> uint64(r)
-			}
-		}
-	case tokenPlus:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l + r
-			case float64:
-				e.result = float64(l) + r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l + r
-			case int64:
-				e.result = l + float64(r)
-			}
-		}
-	case tokenMinus:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l - r
-			case float64:
-				e.result = float64(l) - r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l - r
-			case int64:
-				e.result = l - float64(r)
-			}
-		}
-	case tokenStar:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l * r
-			case float64:
-				e.result = float64(l) * r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l * r
-			case int64:
-				e.result = l * float64(r)
-			}
-		}
-	case tokenSlash:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l / r
-			case float64:
-				e.result = float64(l) / r
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				e.result = l / r
-			case int64:
-				e.result = l / float64(r)
-			}
-		}
-	case tokenPercent:
-		switch l := left.(type) {
-		case int64:
-			switch r := right.(type) {
-			case int64:
-				e.result = l % r
-			}
+func (e *evaluator) visitCompareExpr(c *compareExpr) {
+	// Each operand is evaluated once, and the chain stops at the first
+	// comparison that is false.
+	var result interface{} = true
+	left := e.evaluate(c.operands[0])
+	for i, op := range c.ops {
+		right := e.evaluate(c.operands[i+1])
+		r := e.binary(op, left, right)
+		if r == false {
+			e.result = false
+			return
 		}
-	default:
-		e.error("Unsupported binary operator %v", b.op)
-	}
-
-	if e.result == nil {
-		e.error("Binary operation type error; left: %v (%T), right: %v (%T), op: %v",
-			left, left, right, right, b.op)
+		result = e.binary(andOp, result, r)
+		left = right
 	}
+	e.result = result
 }
 
 func createFunc(e *evaluator, arg expr) func() interface{} {
@@ -286,98 +83,182 @@ func (e *evaluator) mapLazy(args []expr) []func() interface{} {
 }
 
 func (e *evaluator) visitFuncExpr(f *funcExpr) {
+	if v, ok := e.lookup(f.function); ok {
+		c, ok := v.(*closure)
+		if !ok {
+			e.error("Cannot call %s, which is %v (%T)", f.function, v, v)
+		}
+		args := make([]interface{}, len(f.args))
+		for i, arg := range f.args {
+			args[i] = e.evaluate(arg)
+		}
+		e.result = e.call(c, args...)
+		return
+	}
+
 	if e.funcHandler != nil {
 		res, handled := e.funcHandler(f.function, e.mapLazy(f.args)...)
 		if handled {
-			switch r := res.(type) {
-			case int:
-				res = int64(r)
+			if res = e.normalize(res); res == nil {
+				e.error("Function %s returned no value", f.function)
 			}
 			e.result = res
 			return
 		}
 	}
 
-	switch f.function {
-	case "abs":
-		if l := len(f.args); l != 1 {
-			e.error("abs takes one param, got %d", l)
-			return
-		}
-
-		r := e.evaluate(f.args[0])
-		switch f := r.(type) {
-		case int64:
-			if f < 0 {
-				e.result = -f
-			}
-		case float64:
-			if f < 0 {
-				e.result = -f
-			}
-		}
-	default:
-		e.error("Unrecognized function %s", f.function)
+	if b, ok := builtins[f.function]; ok {
+		e.result = b(e, f)
+		return
 	}
+
+	e.error("Unrecognized function %s", f.function)
 }
 
 func (e *evaluator) visitUnaryExpr(u *unaryExpr) {
-	u.expr.accept(e)
-	operand := e.result
-
-	e.result = nil
-
-	switch u.op.typ {
-	case tokenMinus:
-		switch r := operand.(type) {
-		case int64:
-			e.result = -r
-		case float64:
-			e.result = -r
-		}
-	case tokenLogicalNot:
-		switch r := operand.(type) {
-		case bool:
-			e.result = !r
-		}
-	case tokenBitwiseNot:
-		switch r := operand.(type) {
-		case int64:
-			e.result = ^r
-		}
-	default:
-		e.error("Unsupported unary operator %v", u.op)
+	operand := e.evaluate(u.expr)
+	e.result = e.unary(u.op, operand)
+}
+
+func (e *evaluator) visitIsNullExpr(i *isNullExpr) {
+	isNull := e.evaluate(i.x) == Null
+	e.result = isNull != i.not
+}
+
+// Operators used to evaluate between like the comparisons it stands for.
+var (
+	andOp            = &token{typ: tokenLogicalAnd, val: "&&"}
+	notOp            = &token{typ: tokenLogicalNot, val: "!"}
+	lessOrEqualOp    = &token{typ: tokenLessOrEqual, val: "<="}
+	greaterOrEqualOp = &token{typ: tokenGreaterOrEqual, val: ">="}
+)
+
+func (e *evaluator) visitBetweenExpr(b *betweenExpr) {
+	x := e.evaluate(b.x)
+	low := e.evaluate(b.low)
+	high := e.evaluate(b.high)
+	r := e.binary(andOp, e.binary(greaterOrEqualOp, x, low), e.binary(lessOrEqualOp, x, high))
+	if b.not {
+		r = e.unary(notOp, r)
 	}
+	e.result = r
+}
 
-	if e.result == nil {
-		e.error("Unary operation type mismatch; operator: %v, operand: %v (%T)", u.op, operand, operand)
+func (e *evaluator) visitListExpr(l *listExpr) {
+	list := make([]interface{}, len(l.elems))
+	for i, elem := range l.elems {
+		list[i] = e.evaluate(elem)
 	}
+	e.result = list
+}
+
+func (e *evaluator) visitMapExpr(m *mapExpr) {
+	r := make(map[string]interface{}, len(m.keys))
+	for i, key := range m.keys {
+		r[key] = e.evaluate(m.values[i])
+	}
+	e.result = r
 }
 
 func (e *evaluator) visitBoolExpr(b *boolExpr) {
 	e.result = b.val
 }
 
+func (e *evaluator) visitNullExpr(n *nullExpr) {
+	e.result = Null
+}
+
 func (e *evaluator) visitFloatExpr(f *floatExpr) {
 	e.result = f.val
 }
 
+func (e *evaluator) visitComplexExpr(c *complexExpr) {
+	e.result = c.val
+}
+
+func (e *evaluator) visitDecimalExpr(d *decimalExpr) {
+	e.result = d.val
+}
+
 func (e *evaluator) visitIntExpr(i *intExpr) {
 	e.result = i.val
 }
 
+func (e *evaluator) visitUintExpr(u *uintExpr) {
+	e.result = u.val
+}
+
+func (e *evaluator) visitBigIntExpr(b *bigIntExpr) {
+	// Copy, so that callers cannot modify the literal through the result.
+	e.result = new(big.Int).Set(b.val)
+}
+
+func (e *evaluator) visitDurationExpr(d *durationExpr) {
+	e.result = d.val
+}
+
+func (e *evaluator) visitQuantityExpr(q *quantityExpr) {
+	e.result = Quantity{e.evaluate(q.x), q.unit}
+}
+
+func (e *evaluator) visitBadExpr(b *badExpr) {
+	e.error("Cannot evaluate invalid expression at %d", b.pos)
+}
+
 func (e *evaluator) visitParamExpr(p *paramExpr) {
+	if res, ok := e.lookup(p.identifier); ok {
+		e.result = res
+		return
+	}
+	if res, ok := e.resolve(p.identifier); ok {
+		e.result = res
+		return
+	}
+	if e.config.nulls {
+		e.result = Null
+		return
+	}
+
+	e.error("Identifier \"%s\" undefined", p.identifier)
+}
+
+func (e *evaluator) resolve(identifier string) (interface{}, bool) {
 	if e.paramResolver != nil {
-		if res := e.paramResolver(p.identifier); res != nil {
-			switch r := res.(type) {
-			case int:
-				res = int64(r)
-			}
+		if res := e.normalize(e.paramResolver(identifier)); res != nil {
+			return res, true
+		}
+	}
+	return nil, false
+}
 
+func (e *evaluator) visitSelectorExpr(s *selectorExpr) {
+	// Resolvers may know a dotted path as a whole, so offer it to them before
+	// walking it one field at a time.
+	if path, ok := selectorPath(s); ok && !e.bound(path) {
+		if res, ok := e.resolve(path); ok {
 			e.result = res
 			return
 		}
 	}
 
-	e.error("Identifier \"%s\" undefined", p.identifier)
+	x := e.evaluate(s.x)
+	if x == Null {
+		e.result = Null
+		return
+	}
+	e.result = e.field(x, s.sel, s.pos)
+}
+
+func (e *evaluator) visitIndexExpr(i *indexExpr) {
+	x := e.evaluate(i.x)
+	index := e.evaluate(i.index)
+	if x == Null || index == Null {
+		e.result = Null
+		return
+	}
+	e.result = e.index(x, index, i.lbrack)
+}
+
+func (e *evaluator) visitStringExpr(s *stringExpr) {
+	e.result = s.val
 }
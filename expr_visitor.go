@@ -2,11 +2,32 @@ package gocalc
 
 type exprVisitor interface {
 	visitBinaryExpr(*binaryExpr)
+	visitCompareExpr(*compareExpr)
 	visitFuncExpr(*funcExpr)
 	visitUnaryExpr(*unaryExpr)
 	visitParamExpr(*paramExpr)
+	visitSelectorExpr(*selectorExpr)
+	visitIndexExpr(*indexExpr)
+	visitIsNullExpr(*isNullExpr)
+	visitBetweenExpr(*betweenExpr)
 
+	visitListExpr(*listExpr)
+	visitMapExpr(*mapExpr)
+	visitLambdaExpr(*lambdaExpr)
+	visitLetExpr(*letExpr)
+	visitProgramExpr(*programExpr)
+	visitAssignExpr(*assignExpr)
 	visitBoolExpr(*boolExpr)
+	visitNullExpr(*nullExpr)
 	visitFloatExpr(*floatExpr)
+	visitComplexExpr(*complexExpr)
+	visitDecimalExpr(*decimalExpr)
 	visitIntExpr(*intExpr)
+	visitUintExpr(*uintExpr)
+	visitBigIntExpr(*bigIntExpr)
+	visitDurationExpr(*durationExpr)
+	visitQuantityExpr(*quantityExpr)
+	visitStringExpr(*stringExpr)
+
+	visitBadExpr(*badExpr)
 }
@@ -8,27 +8,39 @@ import "fmt"
 // instead resolved during each evaluation.
 //
 type Expression struct {
-	tree expr
-	raw  string
+	tree   expr
+	raw    string
+	config *config
 }
 
 // NewExpr initializes and returns an Expression given the string
-// representation, or an error if compilation failed.
+// representation and any options, or an error if compilation failed. The
+// error is of type Diagnostics, and lists every problem found in the
+// expression.
 //
-func NewExpr(expr string) (*Expression, error) {
-	l := newLexer(expr)
-	p := newParser(l)
+func NewExpr(expr string, opts ...Option) (*Expression, error) {
+	p := newExprParser(expr, opts)
 	t := p.parseExpr()
 	if t == nil {
-		return nil, compileError(p.error)
+		return nil, p.diagnostics
 	}
 
 	return &Expression{
-		tree: t,
-		raw:  expr,
+		tree:   t,
+		raw:    expr,
+		config: p.config,
 	}, nil
 }
 
+// Check compiles expr with the given options and returns every diagnostic
+// found, or nil if expr is valid.
+//
+func Check(expr string, opts ...Option) []Diagnostic {
+	p := newExprParser(expr, opts)
+	p.parseExpr()
+	return p.diagnostics
+}
+
 // ParamResolver resolves the values of any identifiers within an Expression.
 //
 type ParamResolver func(string) (value interface{})
@@ -44,6 +56,20 @@ type FuncHandler func(string, ...func() interface{}) (result interface{}, handle
 // returned, or an error if evaluation failed.
 //
 func (e *Expression) Evaluate(p ParamResolver, f FuncHandler) (result interface{}, err error) {
+	result, _, err = e.evaluate(p, f, false)
+	return result, err
+}
+
+// EvaluateBindings evaluates an Expression like Evaluate, and also returns
+// the final value of each name assigned by a program compiled with
+// WithProgram. Names bound to lambdas, or to lists and maps holding them,
+// are left out.
+//
+func (e *Expression) EvaluateBindings(p ParamResolver, f FuncHandler) (result interface{}, bindings map[string]interface{}, err error) {
+	return e.evaluate(p, f, true)
+}
+
+func (e *Expression) evaluate(p ParamResolver, f FuncHandler, bind bool) (result interface{}, bindings map[string]interface{}, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			switch er := r.(type) {
@@ -55,14 +81,16 @@ func (e *Expression) Evaluate(p ParamResolver, f FuncHandler) (result interface{
 		}
 	}()
 
-	v := newEvaluator(p, f)
-	return v.evaluate(e.tree), nil
-}
-
-// CompileError represents a compilation error of an expression.
-//
-type compileError string
-
-func (c compileError) Error() string {
-	return string(c)
+	v := newEvaluator(e.config, p, f)
+	result = v.evaluate(e.tree)
+	if c := lambdaIn(result); c != nil {
+		return nil, nil, EvaluationError(fmt.Sprintf("Expression evaluated to the lambda %v, which is not a value", c))
+	}
+	if e.config.demote {
+		result = demote(result)
+	}
+	if bind {
+		bindings = v.bindings()
+	}
+	return result, bindings, nil
 }
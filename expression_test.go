@@ -2,6 +2,9 @@ package gocalc
 
 import (
 	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -23,10 +26,11 @@ var tests = []expressionTest{
 	{false, "1<", nil},
 	{false, "f(a a", nil},
 	{false, "1.0a", nil},
-	{false, "0d", nil},
+	{false, "0q", nil},
 	{false, "07c", nil},
 	{false, "0bb", nil},
 	{false, "0xabcg", nil},
+	{false, "99999999999999999999", nil},
 
 	// Binary expressions
 
@@ -36,11 +40,39 @@ var tests = []expressionTest{
 	{true, "1 > 0 || 2 > 1", true},
 	{false, "9 || 10", nil},
 	{false, "3.5 || -1", nil},
+	{true, "1 < 0 or 2 > 1", true},
+	{false, "1 or 2", nil},
 
 	// Logical and
 	{true, "true && false", false},
 	{true, "true && true", true},
 	{true, "1 > 2 && true", false},
+	{true, "1 < 2 and 2 < 3", true},
+	{true, "true or false and false", true},
+	{true, "(true or false) and false", false},
+
+	// Keyword tests
+	{true, "not false", true},
+	{true, "not 1 > 2", true},
+	{true, "not 1 == 1 and false", false},
+	{true, "not 1 in [2] or false", true},
+	{false, "not 1", nil},
+	{true, "not (1 > 2)", true},
+	{true, "not not true", true},
+	{true, "2 not in [1, 3]", true},
+	{true, "2 not in [1, 2] or true", true},
+	{true, "\"z\" not in \"abc\"", true},
+	{true, "5 between 1 and 10", true},
+	{true, "5 between 6 and 10", false},
+	{true, "5.5 between 5 and 6 and true", true},
+	{true, "1 + 1 between 1 * 2 and 2 + 1", true},
+	{true, "5 not between 1 and 10", false},
+	{true, "\"b\" between \"a\" and \"c\"", true},
+	{true, "(5 between 1 and 10) = true", true},
+	{false, "1 not in 2", nil},
+	{false, "true between 1 and 2", nil},
+	{false, "1 between 2", nil},
+	{false, "1 not 2", nil},
 
 	// Bitwise or
 	{true, "1 | 2", 3},
@@ -61,6 +93,11 @@ var tests = []expressionTest{
 	{true, "true=false", false},
 	{true, "1.0=1.0", true},
 	{true, "3.5=4.0", false},
+	{true, "1=1.0", true},
+	{true, "1==1", true},
+	{true, "3 == 5", false},
+	{true, "true == (1 == 1.0)", true},
+	{false, "1 === 1", nil},
 
 	// Not equal
 	{true, "1!=1", false},
@@ -78,6 +115,22 @@ var tests = []expressionTest{
 	{true, "5.0 < 10.0", true},
 	{true, "9.0 < 4.0", false},
 
+	// Chained comparisons
+	{true, "1 < 2 < 3", true},
+	{true, "3 > 2 > 1", true},
+	{true, "1 < 3 < 2", false},
+	{true, "0 <= 5.5 < 10", true},
+	{true, "0 <= 10 < 10", false},
+	{true, "1 < 2 <= 2 < 3 >= 0", true},
+	{true, "2 > 1 < 3", true},
+	{true, "1 < 2 < 3 && 3 > 2 > 1", true},
+	{true, "(1 < 2) == (2 < 3)", true},
+	{true, "2 > 3 < \"a\"", false},
+	{false, "1 < 2 < \"a\"", nil},
+	{false, "(1 < 2) < 3", nil},
+	{false, "1 < 2 == true", nil},
+	{false, "true != 1 < 2", nil},
+
 	// Less or equal
 	{true, "1 <= 1", true},
 	{true, "1 <= 2", true},
@@ -137,11 +190,16 @@ var tests = []expressionTest{
 	{true, "1 / 4.0", 0.25},
 	{true, "4.0 / 2.0", 2.0},
 
+	{false, "1 / 0", nil},
+	{false, "0 % 0", nil},
+
 	// Percent
 	{true, "5 % 3", 2},
 
 	// Unary
 	{true, "-1", -1},
+	{true, "+1", 1},
+	{true, "++2.5", 2.5},
 	{true, "!false", true},
 	{true, "~2", -3},
 	{false, "~2.0", nil},
@@ -151,10 +209,27 @@ var tests = []expressionTest{
 	{true, "abs(-1.0)", 1.0},
 	{true, "abs(4)", 4},
 	{true, "abs(9.0)", 9.0},
+	{false, "abs(true)", nil},
+	{true, "round(2.5)", 2.0},
+	{true, "round(3.5)", 4.0},
+	{true, "round(1.005, 2)", 1.0},
+	{true, "round(2.675, 2)", 2.68},
+	{true, "round(1234, -2)", 1200},
+	{true, "round(7)", 7},
+	{true, "floor(-1.5)", -2.0},
+	{true, "ceil(1.2)", 2.0},
+	{true, "floor(3)", 3},
+	{false, "round(1.5, 0.5)", nil},
+	{false, "round()", nil},
+	{false, "floor(1, 2)", nil},
 	{false, "g()", nil},
 
 	// Identifiers
 	{false, "d", nil},
+
+	// Literals
+	{true, "4294967296", 4294967296},
+	{true, "0x7fffffffffffffff", 9223372036854775807},
 }
 
 var resolverTests = []resolverExpressionTest{
@@ -166,6 +241,19 @@ var resolverTests = []resolverExpressionTest{
 		return nil
 	}, nil},
 
+	{expressionTest{true, "größe * 2 + order.total - rate_2024", 8}, func(s string) interface{} {
+		switch s {
+		case "größe":
+			return 3
+		case "order.total":
+			return 4
+		case "rate_2024":
+			return 2
+		}
+
+		return nil
+	}, nil},
+
 	{expressionTest{true, "abs(-3)", 3}, nil, func(f string, args ...func() interface{}) (interface{}, bool) {
 		return nil, false
 	}},
@@ -176,6 +264,10 @@ var resolverTests = []resolverExpressionTest{
 		return args[0]().(int), true
 	}},
 
+	{expressionTest{false, "f()", nil}, nil, func(f string, args ...func() interface{}) (interface{}, bool) {
+		return nil, true
+	}},
+
 	{expressionTest{true, "add(1, 2)", 3}, nil, func(f string, args ...func() interface{}) (interface{}, bool) {
 		if f == "add" {
 			if l := len(args); l != 2 {
@@ -197,36 +289,69 @@ func allTests() []resolverExpressionTest {
 	return r
 }
 
+// checkEvaluation compiles and evaluates test.expr and reports whether the
+// outcome matches test. It returns the compilation or evaluation error, if
+// any, so that callers can inspect it further.
+func checkEvaluation(t *testing.T, test expressionTest, p ParamResolver, f FuncHandler, opts ...Option) error {
+	t.Helper()
+	e, err := NewExpr(test.expr, opts...)
+	if err != nil {
+		if test.ok {
+			t.Errorf("Expression \"%v\": Cannot test; lexer or parser error: %v", test.expr, err)
+		} else {
+			t.Logf("Logging expected compilation error: \"%v\"", err.Error())
+		}
+		return err
+	}
+
+	res, err := e.Evaluate(p, f)
+	if test.ok && err != nil {
+		t.Errorf("Expression \"%v\": Evaluation failed with error: %v, expected result: %v",
+			test.expr, err, test.expect)
+	} else if test.ok && !evaluatedTo(res, test.expect) {
+		t.Errorf("Expression \"%v\": Evaluation returned %v (%T), expected %v (%T)",
+			test.expr, res, res, test.expect, test.expect)
+	} else if !test.ok && err == nil {
+		t.Errorf("Expression \"%v\": Evaluation passed but should have failed, returning %v", test.expr, res)
+	} else if !test.ok {
+		t.Logf("Logging expected evaluation error: \"%v\"", err.Error())
+	}
+	return err
+}
+
+// evaluatedTo reports whether res has the dynamic type and the value of
+// expect. An int expectation stands for an int64 result. Decimals and big
+// numbers hold pointers, so they are compared by their printed form.
+func evaluatedTo(res, expect interface{}) bool {
+	if r, ok := expect.(int); ok {
+		expect = int64(r)
+	}
+	switch expect.(type) {
+	case Decimal, *big.Int, *big.Rat:
+		return reflect.TypeOf(res) == reflect.TypeOf(expect) && fmt.Sprint(res) == fmt.Sprint(expect)
+	}
+	return reflect.DeepEqual(res, expect)
+}
+
 func TestExpressionEvaluation(t *testing.T) {
 	for _, test := range allTests() {
-		e, err := NewExpr(test.expr)
-		if err != nil {
-			if test.ok {
-				t.Errorf("Expression \"%v\": Cannot test; lexer or parser error: %v", test.expr, err)
-			} else {
-				t.Logf("Logging expected compilation error: \"%v\"", err.Error())
-			}
-
-			continue
-		}
+		checkEvaluation(t, test.expressionTest, test.p, test.f)
+	}
+}
 
-		switch r := test.expect.(type) {
-		case int:
-			test.expect = int64(r)
-		}
+// singleEqual matches a lone "=", which is not part of "==", "!=", "<=",
+// ">=" or "=>".
+var singleEqual = regexp.MustCompile(`(^|[^=!<>])=($|[^=>])`)
 
-		res, err := e.Evaluate(test.p, test.f)
-		if test.ok && err != nil {
-			t.Errorf("Expression \"%v\": Evaluation failed with error: %v, expected result: %v",
-				test.expr, err, test.expect)
-		} else if test.ok && test.expect != res {
-			t.Errorf("Expression \"%v\": Evaluation returned %v (%T), expected %v (%T)",
-				test.expr, res, res, test.expect, test.expect)
-		} else if !test.ok && err == nil {
-			t.Errorf("Expression \"%v\": Evaluation passed but should have failed", test.expr)
-		} else if !test.ok {
-			t.Logf("Logging expected evaluation error: \"%v\"", err.Error())
+func TestExpressionEvaluationStrict(t *testing.T) {
+	for _, test := range allTests() {
+		if singleEqual.MatchString(test.expr) {
+			if _, err := NewExpr(test.expr, WithSyntax(SyntaxStrict)); err == nil {
+				t.Errorf("Expression \"%v\": Compiled with a lone \"=\" in strict syntax", test.expr)
+			}
+			test.expr = singleEqual.ReplaceAllString(test.expr, "$1==$2")
 		}
+		checkEvaluation(t, test.expressionTest, test.p, test.f, WithSyntax(SyntaxStrict))
 	}
 }
 
@@ -357,3 +482,88 @@ func Example_float() {
 	// Output:
 	// 32.5
 }
+
+func FuzzNewExpr(f *testing.F) {
+	for _, test := range allTests() {
+		f.Add(test.expr)
+	}
+	for _, test := range mapTests {
+		f.Add(test.expr)
+	}
+	for _, test := range letTests {
+		f.Add(test.expr)
+	}
+	for _, test := range timeTests {
+		f.Add(test.expr)
+	}
+
+	for _, test := range programTests {
+		f.Add(test.src)
+	}
+	for _, src := range []string{"a ?? b ?? -c", "√√x ** 2 ** ¬y", "(?x) + (¬y) mod 2", "a~=b<>c ≠ d",
+		"5[km] + 300[m]", "10[kg] * 9.81[m/s^2] < 1[kN]", `to(2[m s^-1], "km/h")`, "1.5 [0][1]"} {
+		f.Add(src)
+	}
+
+	d := testDialect(f)
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, opts := range [][]Option{nil, {WithProgram()}, {WithMathNotation()}, {WithDialect(d)}} {
+			e, err := NewExpr(s, opts...)
+			if (e == nil) == (err == nil) {
+				t.Fatalf("Expression \"%v\": got expression %v and error %v", s, e, err)
+			}
+			if e == nil {
+				continue
+			}
+			printed, err := NewExpr(e.String(), opts...)
+			if err != nil {
+				t.Fatalf("Expression \"%v\": printed %v, which does not compile: %v", s, e, err)
+			}
+			if printed.String() != e.String() {
+				t.Fatalf("Expression \"%v\": printed %v, which prints as %v", s, e, printed)
+			}
+		}
+	})
+}
+
+func FuzzEvaluate(f *testing.F) {
+	for _, test := range allTests() {
+		f.Add(test.expr, int64(5), 2.5)
+	}
+
+	f.Fuzz(func(t *testing.T, s string, i int64, x float64) {
+		e, err := NewExpr(s)
+		if err != nil {
+			return
+		}
+
+		r := func(id string) interface{} {
+			switch id {
+			case "a":
+				return i
+			case "b":
+				return x
+			case "c":
+				return i > 0
+			}
+			return nil
+		}
+		h := func(fn string, args ...func() interface{}) (interface{}, bool) {
+			switch fn {
+			case "first":
+				if len(args) > 0 {
+					return args[0](), true
+				}
+			}
+			return nil, false
+		}
+		res, err := e.Evaluate(r, h)
+		if err != nil {
+			if _, ok := err.(EvaluationError); !ok {
+				t.Fatalf("Expression \"%v\": got non-evaluation error %T: %v", s, err, err)
+			}
+		} else if res == nil {
+			t.Fatalf("Expression \"%v\": got nil result without an error", s)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+)
+
+// An Interval is the range of real numbers from Lo to Hi, inclusive, that a
+// value known only approximately lies within, like a measurement and its
+// uncertainty. Resolvers and FuncHandlers may return Intervals, and the
+// arithmetic operators applied to them return the Interval that the result
+// lies within, whatever values within them the operands have: if x is
+// [1.9, 2.1], then x * x - 3 / x is about [2.031, 2.981]. Numbers are
+// intervals of one value, and the bounds are float64s. A bound that float64
+// arithmetic cannot compute exactly is rounded outward, to the next float64
+// below a lower bound or above an upper one, so that the result still lies
+// within the Interval.
+//
+// Each use of an Interval ranges over it independently, so x - x is
+// about [-0.2, 0.2] rather than 0, and bounds may be wider than the range of
+// the result. Dividing by an Interval that contains zero fails, and % does
+// not apply to Intervals.
+//
+// Comparisons of Intervals are true if they hold for all of the values
+// within them, false if they hold for none, and Null, for unknown, if they
+// hold for some only. So x > 1 is true, x > 2 Null and x > 3 false, and
+// x == 2 is Null, being true only if both are the same single number.
+//
+type Interval struct {
+	Lo float64
+	Hi float64
+}
+
+// String returns the bounds of i in brackets.
+//
+func (i Interval) String() string {
+	return fmt.Sprintf("[%v, %v]", i.Lo, i.Hi)
+}
+
+// interval applies op to left and right if either is an Interval and the
+// other an Interval or real number, or returns nil.
+func (e *evaluator) interval(op *token, left, right interface{}) interface{} {
+	_, lok := left.(Interval)
+	_, rok := right.(Interval)
+	if !lok && !rok {
+		return nil
+	}
+	l, ok := e.toInterval(left)
+	if !ok {
+		return nil
+	}
+	r, ok := e.toInterval(right)
+	if !ok {
+		return nil
+	}
+	switch op.typ {
+	case tokenPlus:
+		return e.bounds(op, l, r, add(l.Lo, r.Lo, false), add(l.Hi, r.Hi, true))
+	case tokenMinus:
+		return e.bounds(op, l, r, add(l.Lo, -r.Hi, false), add(l.Hi, -r.Lo, true))
+	case tokenStar:
+		return e.product(op, l, r)
+	case tokenSlash:
+		if r.Lo <= 0 && r.Hi >= 0 {
+			e.error("Interval division by %v, which contains zero", r)
+		}
+		return e.product(op, l, Interval{inverse(r.Hi, false), inverse(r.Lo, true)})
+	case tokenLessThan:
+		return certain(l.Hi < r.Lo, l.Lo >= r.Hi)
+	case tokenLessOrEqual:
+		return certain(l.Hi <= r.Lo, l.Lo > r.Hi)
+	case tokenGreaterThan:
+		return certain(l.Lo > r.Hi, l.Hi <= r.Lo)
+	case tokenGreaterOrEqual:
+		return certain(l.Lo >= r.Hi, l.Hi < r.Lo)
+	case tokenEqual:
+		return certain(l == r && l.Lo == l.Hi, l.Hi < r.Lo || r.Hi < l.Lo)
+	case tokenNotEqual:
+		return certain(l.Hi < r.Lo || r.Hi < l.Lo, l == r && l.Lo == l.Hi)
+	}
+	return nil
+}
+
+// toInterval returns x as an Interval, which for a real number is that
+// number alone. ok is false if x is neither.
+func (e *evaluator) toInterval(x interface{}) (i Interval, ok bool) {
+	if i, ok := x.(Interval); ok {
+		if !(i.Lo <= i.Hi) {
+			e.error("Invalid interval %v", i)
+		}
+		return i, true
+	}
+	if r := rank(x); r == rankNone || r == rankComplex {
+		return Interval{}, false
+	}
+	c, _ := toComplex(x)
+	return Interval{real(c), real(c)}, true
+}
+
+// product returns l times r.
+func (e *evaluator) product(op *token, l, r Interval) Interval {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, x := range []float64{l.Lo, l.Hi} {
+		for _, y := range []float64{r.Lo, r.Hi} {
+			lo = math.Min(lo, mul(x, y, false))
+			hi = math.Max(hi, mul(x, y, true))
+		}
+	}
+	return e.bounds(op, l, r, lo, hi)
+}
+
+// bounds returns the Interval from lo to hi that is the result of l op r,
+// failing the evaluation if the result is undefined, as for infinities
+// subtracted from one another.
+func (e *evaluator) bounds(op *token, l, r Interval, lo, hi float64) Interval {
+	if math.IsNaN(lo) || math.IsNaN(hi) {
+		e.error("Interval %v %s %v is undefined", l, op, r)
+	}
+	return Interval{lo, hi}
+}
+
+// add returns x + y, rounded up if up is true and down otherwise.
+func add(x, y float64, up bool) float64 {
+	s := x + y
+	if math.IsInf(s, 0) || math.IsNaN(s) {
+		return overflow(s, !math.IsInf(x, 0) && !math.IsInf(y, 0), up)
+	}
+	// The error of s, found as in Knuth's TwoSum.
+	t := s - x
+	return outward(s, (x-(s-t))+(y-t), up)
+}
+
+// mul returns x * y, rounded up if up is true and down otherwise. A bound
+// of zero times an infinite one is zero, since the infinite bound is never
+// reached.
+func mul(x, y float64, up bool) float64 {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	p := x * y
+	if math.IsInf(p, 0) {
+		return overflow(p, !math.IsInf(x, 0) && !math.IsInf(y, 0), up)
+	}
+	return outward(p, math.FMA(x, y, -p), up)
+}
+
+// inverse returns 1 / x, rounded up if up is true and down otherwise.
+func inverse(x float64, up bool) float64 {
+	q := 1 / x
+	if q == 0 || math.IsInf(x, 0) {
+		return q
+	}
+	// 1 / x - q has the sign of (1 - q * x) / x.
+	err := -math.FMA(q, x, -1)
+	if x < 0 {
+		err = -err
+	}
+	return outward(q, err, up)
+}
+
+// outward returns x, the rounded value of a bound that lies err above it,
+// moved to the next float64 in the direction of that bound if err is not
+// zero.
+func outward(x, err float64, up bool) float64 {
+	switch {
+	case up && err > 0:
+		return math.Nextafter(x, math.Inf(1))
+	case !up && err < 0:
+		return math.Nextafter(x, math.Inf(-1))
+	}
+	return x
+}
+
+// overflow returns x, an infinite or undefined bound. If finite is true, the
+// bound is finite but too large for a float64, and a bound that should be
+// rounded toward zero is the largest float64 instead.
+func overflow(x float64, finite, up bool) float64 {
+	switch {
+	case !finite || math.IsNaN(x):
+		return x
+	case x > 0 && !up:
+		return math.MaxFloat64
+	case x < 0 && up:
+		return -math.MaxFloat64
+	}
+	return x
+}
+
+// certain returns true if yes holds, false if no does, and Null, for
+// unknown, if neither does.
+func certain(yes, no bool) interface{} {
+	switch {
+	case yes:
+		return true
+	case no:
+		return false
+	}
+	return Null
+}
+
+// intervalArg returns args[0] as an Interval. ok is false if it is a real
+// number instead.
+func (e *evaluator) intervalArg(f *funcExpr, args []interface{}) (i Interval, ok bool) {
+	if _, ok := args[0].(Interval); !ok {
+		if _, ok := e.toInterval(args[0]); !ok {
+			e.error("%s requires an interval or real number, got %v (%T)", f.function, args[0], args[0])
+		}
+		return Interval{}, false
+	}
+	return e.toInterval(args[0])
+}
+
+// lo(x) returns the lower bound of the interval x, or x if it is a number.
+func builtinLo(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if i, ok := e.intervalArg(f, args); ok {
+		return e.adapt(i.Lo)
+	}
+	return args[0]
+}
+
+// hi(x) returns the upper bound of the interval x, or x if it is a number.
+func builtinHi(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if i, ok := e.intervalArg(f, args); ok {
+		return e.adapt(i.Hi)
+	}
+	return args[0]
+}
+
+// mid(x) returns the midpoint of the interval x, or x if it is a number.
+func builtinMid(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if i, ok := e.intervalArg(f, args); ok {
+		// Halving first cannot overflow.
+		return e.adapt(i.Lo/2 + i.Hi/2)
+	}
+	return args[0]
+}
+
+// width(x) returns the width of the interval x, or 0 if it is a number.
+func builtinWidth(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if i, ok := e.intervalArg(f, args); ok {
+		return e.adapt(i.Hi - i.Lo)
+	}
+	return e.adapt(int64(0))
+}
@@ -0,0 +1,152 @@
+package gocalc
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestInterval(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"x":    Interval{1.9, 2.1},
+		"a":    Interval{1, 2},
+		"b":    &Interval{-1, 2},
+		"c":    Interval{3, 4},
+		"d":    Interval{2, 2},
+		"z":    Interval{0, 1},
+		"up":   Interval{1, math.Inf(1)},
+		"all":  Interval{math.Inf(-1), math.Inf(1)},
+		"inf":  Interval{math.Inf(1), math.Inf(1)},
+		"bad":  Interval{2, 1},
+		"none": (*Interval)(nil),
+		"max":  Interval{math.MaxFloat64, math.MaxFloat64},
+	})
+
+	for _, test := range []expressionTest{
+		// Arithmetic
+		{true, "x * x - 3 / x", Interval{2.0310526315789463, 2.9814285714285726}},
+		{true, "a + c", Interval{4, 6}},
+		{true, "a - c", Interval{-3, -1}},
+		{true, "a * c", Interval{3, 8}},
+		{true, "b * b", Interval{-2, 4}},
+		{true, "b * -c", Interval{-8, 4}},
+		{true, "a / c", Interval{0.25, math.Nextafter(2.0/3, 1)}},
+		{true, "a / -c", Interval{math.Nextafter(-2.0/3, -1), -0.25}},
+		{true, "max + max", Interval{math.MaxFloat64, math.Inf(1)}},
+		{true, "-max - max", Interval{math.Inf(-1), -math.MaxFloat64}},
+		{true, "-b", Interval{-2, 1}},
+		{true, "+b", Interval{-1, 2}},
+		{true, "a + 1", Interval{2, 3}},
+		{true, "1 - a", Interval{-1, 0}},
+		{true, "2 * a", Interval{2, 4}},
+		{true, "a * 0.5", Interval{0.5, 1}},
+		{true, "4 / a", Interval{2, 4}},
+		{true, "a - a", Interval{-1, 1}},
+		{true, "z * up", Interval{0, math.Inf(1)}},
+		{true, "1 / up", Interval{0, 1}},
+		{true, "all + all", Interval{math.Inf(-1), math.Inf(1)}},
+		{true, "up - up", Interval{math.Inf(-1), math.Inf(1)}},
+		{true, "a + null", Null},
+
+		// Comparisons
+		{true, "a < c", true},
+		{true, "c < a", false},
+		{true, "a < b", Null},
+		{true, "a <= d", true},
+		{true, "a < d", Null},
+		{true, "d >= a", true},
+		{true, "d > a", Null},
+		{true, "x > 1", true},
+		{true, "x > 2", Null},
+		{true, "x > 3", false},
+		{true, "x >= 2.1", Null},
+		{true, "x <= 2.1", true},
+		{true, "1 < x < 3", true},
+		{true, "1 < x < 2", Null},
+		{true, "3 < x < 4", false},
+		{true, "x between 1 and 3", true},
+		{true, "x between 2 and 3", Null},
+		{true, "d == 2", true},
+		{true, "d != 2", false},
+		{true, "a == c", false},
+		{true, "a != c", true},
+		{true, "a == a", Null},
+		{true, "a == 1.5", Null},
+		{true, "x > 2 || true", true},
+		{true, "x > 2 && false", false},
+		{true, `a == "a"`, false},
+		{true, "a in [c, a]", true},
+		{true, "2 in [d]", false},
+		{true, "sort([c, a])[0]", Interval{1, 2}},
+
+		// Bounds
+		{true, "lo(a * c)", 3.0},
+		{true, "hi(a * c)", 8.0},
+		{true, "mid(c)", 3.5},
+		{true, "mid(x)", 2.0},
+		{true, "width(c - a)", 2.0},
+		{true, "lo(3)", int64(3)},
+		{true, "hi(2.5)", 2.5},
+		{true, "mid(3)", int64(3)},
+		{true, "width(3)", int64(0)},
+		{true, "mid(up)", math.Inf(1)},
+		{true, "lo(null)", Null},
+
+		// Errors
+		{false, "a / b", nil},
+		{false, "a / z", nil},
+		{false, "1 / (a - a)", nil},
+		{false, "a % 2", nil},
+		{false, `a + "a"`, nil},
+		{false, "a + 1i", nil},
+		{false, "a && true", nil},
+		{false, "bad + 1", nil},
+		{false, "lo(bad)", nil},
+		{false, "inf - inf", nil},
+		{false, `lo("a")`, nil},
+		{false, "width(1i)", nil},
+		{false, "sort([a, b])", nil},
+		{false, "none", nil},
+	} {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestIntervalContainment(t *testing.T) {
+	rat := func(f float64) *big.Rat { return new(big.Rat).SetFloat64(f) }
+	x := Interval{1.9, 2.1}
+
+	// Each function is increasing over x, so its exact bounds are its values
+	// at the bounds of x, which the computed Interval must contain.
+	for _, test := range []struct {
+		expr  string
+		exact func(x *big.Rat) *big.Rat
+	}{
+		{"x * x - 3 / x", func(x *big.Rat) *big.Rat {
+			sq := new(big.Rat).Mul(x, x)
+			return sq.Sub(sq, new(big.Rat).Quo(big.NewRat(3, 1), x))
+		}},
+		{"x + 0.1", func(x *big.Rat) *big.Rat { return new(big.Rat).Add(x, rat(0.1)) }},
+		{"x / 3", func(x *big.Rat) *big.Rat { return new(big.Rat).Quo(x, big.NewRat(3, 1)) }},
+		{"x * 1.1", func(x *big.Rat) *big.Rat { return new(big.Rat).Mul(x, rat(1.1)) }},
+		{"-1 / x", func(x *big.Rat) *big.Rat { return new(big.Rat).Quo(big.NewRat(-1, 1), x) }},
+	} {
+		e, err := NewExpr(test.expr)
+		if err != nil {
+			t.Errorf("Expression \"%s\": Cannot test; lexer or parser error: %v", test.expr, err)
+			continue
+		}
+		res, err := e.Evaluate(MapEnv(map[string]interface{}{"x": x}), nil)
+		i, ok := res.(Interval)
+		if err != nil || !ok {
+			t.Errorf("Expression \"%s\": returned %v, %v; expected an Interval", test.expr, res, err)
+			continue
+		}
+		if lo := test.exact(rat(x.Lo)); rat(i.Lo).Cmp(lo) > 0 {
+			t.Errorf("Expression \"%s\": lower bound %v is above the minimum %s", test.expr, i.Lo, lo.FloatString(20))
+		}
+		if hi := test.exact(rat(x.Hi)); rat(i.Hi).Cmp(hi) < 0 {
+			t.Errorf("Expression \"%s\": upper bound %v is below the maximum %s", test.expr, i.Hi, hi.FloatString(20))
+		}
+	}
+}
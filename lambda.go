@@ -0,0 +1,197 @@
+package gocalc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxDepth is the maximum number of nested lambda calls, which bounds the
+// recursion of lambdas applied to themselves.
+const maxDepth = 1000
+
+// A scope binds a name to a value, shadowing its parent scopes and the
+// ParamResolver. The empty scope is nil.
+type scope struct {
+	name   string
+	value  interface{}
+	parent *scope
+}
+
+// lookup returns the value bound to name in s or its parents.
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for ; s != nil; s = s.parent {
+		if s.name == name {
+			return s.value, true
+		}
+	}
+	return nil, false
+}
+
+// A closure is the value of a lambda, which keeps the scope that the lambda
+// was evaluated in.
+type closure struct {
+	lambda *lambdaExpr
+	scope  *scope
+}
+
+// String returns the source of the lambda.
+func (c *closure) String() string {
+	p := newPrinter(nil)
+	c.lambda.accept(p)
+	return string(p.buffer)
+}
+
+// lambdaIn returns a closure that v is or that the lists and maps within it
+// hold, or nil if there is none.
+func lambdaIn(v interface{}) *closure {
+	switch v := v.(type) {
+	case *closure:
+		return v
+	case []interface{}:
+		for _, elem := range v {
+			if c := lambdaIn(elem); c != nil {
+				return c
+			}
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			if c := lambdaIn(elem); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) visitLambdaExpr(l *lambdaExpr) {
+	e.result = &closure{l, e.scope}
+}
+
+// call evaluates the body of c with its parameters bound to args.
+func (e *evaluator) call(c *closure, args ...interface{}) interface{} {
+	if len(args) != len(c.lambda.params) {
+		e.error("Lambda %v takes %s, got %d", c, params(len(c.lambda.params)), len(args))
+	}
+	s := c.scope
+	for i, name := range c.lambda.params {
+		s = &scope{name, args[i], s}
+	}
+
+	if e.depth == maxDepth {
+		e.error("Lambda calls nested more than %d deep", maxDepth)
+	}
+	outer := e.scope
+	e.scope = s
+	e.depth++
+	r := e.evaluate(c.lambda.body)
+	e.depth--
+	e.scope = outer
+	return r
+}
+
+// closure returns x, the argument of f, as a closure.
+func (e *evaluator) closure(f *funcExpr, x interface{}) *closure {
+	c, ok := x.(*closure)
+	if !ok {
+		e.error("%s requires a lambda, got %v (%T)", f.function, x, x)
+	}
+	return c
+}
+
+// predicate reports whether c holds for x, which it does not if it returns
+// null, as in a SQL WHERE clause.
+func (e *evaluator) predicate(f *funcExpr, c *closure, x interface{}) bool {
+	switch r := e.call(c, x); r {
+	case true:
+		return true
+	case false, Null:
+		return false
+	default:
+		e.error("%s requires a lambda returning booleans, got %v (%T)", f.function, r, r)
+	}
+	return false
+}
+
+// map(list, fn) returns the results of fn applied to each element of list.
+func builtinMap(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	l, c := e.list(f, args[0]), e.closure(f, args[1])
+	r := make([]interface{}, len(l))
+	for i, v := range l {
+		r[i] = e.call(c, v)
+	}
+	return r
+}
+
+// filter(list, fn) returns the elements of list for which fn returns true.
+func builtinFilter(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	l, c := e.list(f, args[0]), e.closure(f, args[1])
+	r := []interface{}{}
+	for _, v := range l {
+		if e.predicate(f, c, v) {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// reduce(list, init, fn) folds list from the left, calling fn(acc, x) with
+// the result so far, starting at init, and each element. init may be null,
+// like any other accumulated value.
+func builtinReduce(e *evaluator, f *funcExpr) interface{} {
+	args := e.args(f, 3, 3)
+	if args[0] == Null || args[2] == Null {
+		return Null
+	}
+	l, acc, c := e.list(f, args[0]), args[1], e.closure(f, args[2])
+	for _, v := range l {
+		acc = e.call(c, acc, v)
+	}
+	return acc
+}
+
+// sortBy(list, fn) returns a copy of list in ascending order of the keys
+// that fn returns for its elements, with null keys first. Elements with
+// equal keys keep their order.
+func builtinSortBy(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	l, c := e.list(f, args[0]), e.closure(f, args[1])
+	keys := make([]interface{}, len(l))
+	for i, v := range l {
+		keys[i] = e.call(c, v)
+	}
+	return e.order(l, keys)
+}
+
+// groupBy(list, fn) returns a map from each key that fn returns for the
+// elements of list, formatted as a string, to the list of those elements.
+func builtinGroupBy(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	l, c := e.list(f, args[0]), e.closure(f, args[1])
+	r := map[string]interface{}{}
+	for _, v := range l {
+		key := fmt.Sprint(e.call(c, v))
+		group, _ := r[key].([]interface{})
+		r[key] = append(group, v)
+	}
+	return r
+}
+
+// order returns a copy of l sorted stably by keys, which holds the key of
+// each element of l, with null keys first.
+func (e *evaluator) order(l, keys []interface{}) []interface{} {
+	indexes := make([]int, len(l))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := keys[indexes[i]], keys[indexes[j]]
+		if a == Null || b == Null {
+			return a == Null && b != Null
+		}
+		return e.less(a, b)
+	})
+
+	r := make([]interface{}, len(l))
+	for i, index := range indexes {
+		r[i] = l[index]
+	}
+	return r
+}
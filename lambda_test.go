@@ -0,0 +1,93 @@
+package gocalc
+
+import (
+	"fmt"
+	"testing"
+)
+
+type item struct {
+	Name  string
+	Price float64
+	Kind  string
+}
+
+var lambdaTests = []expressionTest{
+	{true, "map([1, 2, 3], x => x * 2)", []interface{}{int64(2), int64(4), int64(6)}},
+	{true, "map([], x => x)", []interface{}{}},
+	{true, "filter(items, x => x.Price > 10)", []interface{}{item{Name: "pen", Price: 12, Kind: "office"}, item{Name: "lamp", Price: 40, Kind: "home"}}},
+	{true, "map(filter(items, i => i.Kind = \"office\"), i => i.Name)", []interface{}{"clip", "pen"}},
+	{true, "filter([1, null, 3], x => x > 1)", []interface{}{int64(3)}},
+	{true, "reduce(xs, 0, (acc, x) => acc + x)", 10},
+	{true, "reduce([], 7, (acc, x) => acc + x)", 7},
+	{true, "reduce([1, 2], null, (a, x) => coalesce(a, 0) + x)", 3},
+	{true, "reduce([], null, (a, x) => a)", Null},
+	{true, "reduce(null, 0, (a, x) => a + x)", Null},
+	{true, "reduce([\"a\", \"b\"], \"\", (s, x) => concat(s, x))", "ab"},
+	{true, "map(sortBy(items, i => -i.Price), i => i.Name)", []interface{}{"lamp", "pen", "clip"}},
+	{true, "sortBy([3, null, 1], x => x)", []interface{}{Null, int64(1), int64(3)}},
+	{true, "map(groupBy(items, i => i.Kind).office, i => i.Price)", []interface{}{1.5, 12.0}},
+	{true, "groupBy(xs, x => x % 2 = 0)", map[string]interface{}{"false": []interface{}{int64(1), int64(3)}, "true": []interface{}{int64(2), int64(4)}}},
+	{true, "any(xs, x => x > 3)", true},
+	{true, "all(xs, x => x > 3)", false},
+	{true, "all(xs, (x) => x > 0)", true},
+	{true, "any([1, null], x => x > 1)", Null},
+	{true, "any([true, false])", true},
+	{true, "map(xs, x => x + limit)", []interface{}{int64(6), int64(7), int64(8), int64(9)}},
+	{true, "map(xs, limit => limit)", []interface{}{int64(1), int64(2), int64(3), int64(4)}},
+	{true, "map(items, i => i.Name)[0]", "clip"},
+	{true, "map(items, i => i.Price)", []interface{}{1.5, 12.0, 40.0}},
+	{true, "i.Price", 100},
+	{true, "map([[1, 2]], l => map(l, x => x + l[0]))", []interface{}{[]interface{}{int64(2), int64(3)}}},
+	{true, "map([x => x + 1], f => f(1))", []interface{}{int64(2)}},
+	{true, "map([1, 2], x => reduce(xs, x, (a, y) => a * y))", []interface{}{int64(24), int64(48)}},
+	{false, "map([1], () => 2)", nil},
+	{false, "map([1], (a, b) => a)", nil},
+	{false, "map(1, x => x)", nil},
+	{false, "map([1], 2)", nil},
+	{false, "filter([1], x => x)", nil},
+	{false, "sortBy([1, \"a\"], x => x)", nil},
+	{false, "map([1], x => y(x))", nil},
+	{false, "x => x", nil},
+	{false, "[x => x]", nil},
+	{false, "{a: x => x}", nil},
+	{false, "{a: [1, {b: x => x}]}", nil},
+	{false, "limit(1)", nil},
+	{false, "map([f => f(f)], g => g(g))", nil},
+}
+
+func TestLambda(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"items": []item{{"clip", 1.5, "office"}, {"pen", 12, "office"}, {"lamp", 40, "home"}},
+		"xs":    []int{1, 2, 3, 4},
+		"limit": 5,
+		"i":     map[string]interface{}{"Price": 100},
+	})
+
+	for _, test := range lambdaTests {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestLambdaShadowsFunctions(t *testing.T) {
+	e, _ := NewExpr("map([1, 2], abs => abs(3))")
+	if _, err := e.Evaluate(nil, nil); err == nil {
+		t.Error("Calling a lambda parameter that is not a lambda passed")
+	}
+
+	e, _ = NewExpr("map([x => x * 10], abs => abs(-3))")
+	if res, err := e.Evaluate(nil, nil); err != nil || fmt.Sprint(res) != "[-30]" {
+		t.Errorf("Evaluation returned %v, %v, expected [-30]", res, err)
+	}
+}
+
+func ExampleExpression_lambda() {
+	expression, _ := NewExpr("reduce(filter(prices, p => p > 10), 0, (sum, p) => sum + p)")
+
+	result, _ := expression.Evaluate(MapEnv(map[string]interface{}{
+		"prices": []float64{4.5, 12, 30.25},
+	}), nil)
+	fmt.Println(result)
+
+	// Output:
+	// 42.25
+}
@@ -0,0 +1,45 @@
+package gocalc
+
+// A lazy is the value of a let binding, which is evaluated when it is first
+// used and then remembered.
+type lazy struct {
+	x     expr
+	scope *scope // scope that x is evaluated in
+	done  bool
+	value interface{}
+}
+
+func (e *evaluator) visitLetExpr(l *letExpr) {
+	// Each binding sees the ones before it, and the body sees them all.
+	s := e.scope
+	for i, name := range l.names {
+		s = &scope{name, &lazy{x: l.values[i], scope: s}, s}
+	}
+
+	outer := e.scope
+	e.scope = s
+	e.result = e.evaluate(l.body)
+	e.scope = outer
+}
+
+// lookup returns the value bound to name in the current scope, evaluating
+// it if it is a let binding that has not been used yet.
+func (e *evaluator) lookup(name string) (interface{}, bool) {
+	v, ok := e.scope.lookup(name)
+	if l, isLazy := v.(*lazy); isLazy {
+		v = e.force(l)
+	}
+	return v, ok
+}
+
+// force returns the value of l.
+func (e *evaluator) force(l *lazy) interface{} {
+	if !l.done {
+		outer := e.scope
+		e.scope = l.scope
+		l.value = e.evaluate(l.x)
+		e.scope = outer
+		l.done = true
+	}
+	return l.value
+}
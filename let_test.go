@@ -0,0 +1,84 @@
+package gocalc
+
+import (
+	"fmt"
+	"testing"
+)
+
+var letTests = []expressionTest{
+	{true, "let x = a * b, y = c in (x - y) / (x + y)", 0.5},
+	{true, "let x = 2 in x * x", 4},
+	{true, "let a = 10 in a + b", 13},
+	{true, "let x = 1, y = x + 1 in y", 2},
+	{true, "let x = 1 in let x = x + 1 in x", 2},
+	{true, "(let x = 1 in x) + x", 4},
+	{true, "let x = 2 in x in [1, 2]", true},
+	{true, "let found = (2 in [1, 2]) in found", true},
+	{true, "let xs = [1, 2 in [2]] in xs[1]", true},
+	{true, "let m = {k: \"a\" in \"abc\"} in m.k", true},
+	{true, "let unused = 1 / 0 in 7", 7},
+	{true, "let f = x => x * a in map([1, 2], f)", []interface{}{2.0, 4.0}},
+	{true, "let f = x => x * a in f(5)", 10.0},
+	{true, "let n = 3 in map([1, 2], x => x + n)", []interface{}{int64(4), int64(5)}},
+	{true, "let a = {b: 5} in a.b", 5},
+	{true, "let x = null in x is null", true},
+	{false, "let x = 1 / 0 in x", nil},
+	{false, "let x = 1 in y", nil},
+	{true, "let x = x + 1 in x", 4},
+}
+
+func TestLet(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"a": 2.0,
+		"b": 3,
+		"c": 2,
+		"x": 3,
+	})
+
+	for _, test := range letTests {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestLetEvaluatesOnce(t *testing.T) {
+	for _, test := range []struct {
+		expr  string
+		calls int
+	}{
+		{"let x = f() in x + x * x", 1},
+		{"let x = f() in 1", 0},
+		{"let x = f() in map([1, 2, 3], y => x + y)", 1},
+		{"let x = f(), y = x + f() in y + y", 2},
+		{"f() + f()", 2},
+	} {
+		calls := 0
+		h := func(fn string, args ...func() interface{}) (interface{}, bool) {
+			calls++
+			return 1, true
+		}
+
+		e, err := NewExpr(test.expr)
+		if err != nil {
+			t.Fatalf("Expression \"%v\": %v", test.expr, err)
+		}
+		if _, err := e.Evaluate(nil, h); err != nil {
+			t.Errorf("Expression \"%v\": Evaluation failed with error: %v", test.expr, err)
+		} else if calls != test.calls {
+			t.Errorf("Expression \"%v\": FuncHandler called %d times, expected %d", test.expr, calls, test.calls)
+		}
+	}
+}
+
+func ExampleExpression_let() {
+	expression, _ := NewExpr("let x = a * b, y = c in (x - y) / (x + y)")
+
+	result, _ := expression.Evaluate(MapEnv(map[string]interface{}{
+		"a": 3.0,
+		"b": 4,
+		"c": 4,
+	}), nil)
+	fmt.Println(result)
+
+	// Output:
+	// 0.5
+}
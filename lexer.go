@@ -1,5 +1,11 @@
 package gocalc
 
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 type lexer interface {
 	token() *token
 	peekToken() *token
@@ -19,10 +25,24 @@ const (
 	stZeroX
 	stHexInt
 	stOctInt
+	stUint
 	stFloat
+	stImaginary
+	stString
+	stStringEsc
+	stStringEnd
 	stLparen
 	stRparen
 	stComma
+	stDot
+	stLbracket
+	stRbracket
+	stLbrace
+	stRbrace
+	stColon
+	stArrow
+	stAssign
+	stSemicolon
 	stLogicalNot
 	stNotEqual
 	stBitwiseNot
@@ -38,31 +58,31 @@ const (
 	stGreaterThan
 	stGreaterOrEqual
 	stEqual
+	stEqualEqual
 	stBitwiseAnd
 	stLogicalAnd
 	stBitwiseXor
 	stBitwiseOr
 	stLogicalOr
-	stT
-	stTr
-	stTru
-	stTrue
-	stF
-	stFa
-	stFal
-	stFals
-	stFalse
 )
 
 const whitespace = "\t\n\r "
-const letters = "abcdefABCDEFghijklmnopqrstuvwxyzGHIJKLMNOPQRSTUVWXYZ"
+const letters = "abcdefABCDEFghijklmnopqrstuvwxyzGHIJKLMNOPQRSTUVWXYZ_"
 const digits = "0123456789"
 const hexDigits = "0123456789abcdefABCDEF"
 const binaryDigits = "01"
 const octalDigits = "01234567"
 const oneToNine = "123456789"
 
-const stateCount uint8 = 44
+// Runes outside of ASCII are looked up by class, using slots of the
+// transition table that no ASCII byte maps to.
+const (
+	classLetter = utf8.RuneSelf + iota // any Unicode letter
+	classDigit                         // any Unicode decimal digit
+	classOther                         // any other non-ASCII rune
+)
+
+const stateCount uint8 = 50
 const maxTransitionCount uint16 = 256
 
 var trans = [stateCount][maxTransitionCount]state{}
@@ -79,10 +99,24 @@ var stateTokens = [...]tokenType{
 	tokenError,          // stZeroX
 	tokenInt,            // stHexInt
 	tokenInt,            // stOctInt
+	tokenUint,           // stUint
 	tokenFloat,          // stFloat
+	tokenImaginary,      // stImaginary
+	tokenError,          // stString
+	tokenError,          // stStringEsc
+	tokenString,         // stStringEnd
 	tokenLeftParen,      // stLparen
 	tokenRightParen,     // stRparen
 	tokenComma,          // stComma
+	tokenDot,            // stDot
+	tokenLeftBracket,    // stLbracket
+	tokenRightBracket,   // stRbracket
+	tokenLeftBrace,      // stLbrace
+	tokenRightBrace,     // stRbrace
+	tokenColon,          // stColon
+	tokenArrow,          // stArrow
+	tokenAssign,         // stAssign
+	tokenSemicolon,      // stSemicolon
 	tokenLogicalNot,     // stLogicalNot
 	tokenNotEqual,       // stNotEqual
 	tokenBitwiseNot,     // stBitwiseNot
@@ -98,20 +132,12 @@ var stateTokens = [...]tokenType{
 	tokenGreaterThan,    // stGreaterThan
 	tokenGreaterOrEqual, // stGreaterOrEqual
 	tokenEqual,          // stEqual
+	tokenEqual,          // stEqualEqual
 	tokenBitwiseAnd,     // stBitwiseAnd
 	tokenLogicalAnd,     // stLogicalAnd
 	tokenBitwiseXor,     // stBitwiseXor
 	tokenBitwiseOr,      // stBitwiseOr
 	tokenLogicalOr,      // stLogicalOr
-	tokenIdentifier,     // stT
-	tokenIdentifier,     // stTr
-	tokenIdentifier,     // stTru
-	tokenTrue,           // stTrue
-	tokenIdentifier,     // stF
-	tokenIdentifier,     // stFa
-	tokenIdentifier,     // stFal
-	tokenIdentifier,     // stFals
-	tokenFalse,          // stFalse
 }
 
 func setTrans(current state, transition string, next state) {
@@ -120,6 +146,42 @@ func setTrans(current state, transition string, next state) {
 	}
 }
 
+func setClassTrans(current state, class rune, next state) {
+	trans[current][class] = next
+}
+
+// setIdTrans makes every identifier character move current to stId.
+func setIdTrans(current state) {
+	setTrans(current, letters+digits, stId)
+	setClassTrans(current, classLetter, stId)
+	setClassTrans(current, classDigit, stId)
+}
+
+// setAnyTrans makes every printable character and any non-ASCII rune move
+// current to next.
+func setAnyTrans(current state, next state) {
+	for r := rune(' '); r < utf8.RuneSelf-1; r++ {
+		trans[current][r] = next
+	}
+	setTrans(current, "\t", next)
+	for r := rune(classLetter); r <= classOther; r++ {
+		setClassTrans(current, r, next)
+	}
+}
+
+// class returns the transition table index for r.
+func class(r rune) rune {
+	switch {
+	case r < utf8.RuneSelf:
+		return r
+	case unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	}
+	return classOther
+}
+
 func init() {
 	setTrans(stStart, whitespace, stWhitespace)
 	setTrans(stWhitespace, whitespace, stWhitespace)
@@ -128,6 +190,13 @@ func init() {
 	setTrans(stStart, "(", stLparen)
 	setTrans(stStart, ")", stRparen)
 	setTrans(stStart, ",", stComma)
+	setTrans(stStart, ".", stDot)
+	setTrans(stStart, "[", stLbracket)
+	setTrans(stStart, "]", stRbracket)
+	setTrans(stStart, "{", stLbrace)
+	setTrans(stStart, "}", stRbrace)
+	setTrans(stStart, ":", stColon)
+	setTrans(stStart, ";", stSemicolon)
 	setTrans(stStart, "~", stBitwiseNot)
 	setTrans(stStart, "*", stStar)
 	setTrans(stStart, "%", stPercent)
@@ -136,8 +205,17 @@ func init() {
 	setTrans(stStart, "-", stMinus)
 	setTrans(stStart, "=", stEqual)
 
+	// Identifiers follow Go's rules
 	setTrans(stStart, letters, stId)
-	setTrans(stId, letters+digits, stId)
+	setClassTrans(stStart, classLetter, stId)
+	setIdTrans(stId)
+
+	// String
+	setTrans(stStart, "\"", stString)
+	setAnyTrans(stString, stString)
+	setTrans(stString, "\\", stStringEsc)
+	setTrans(stString, "\"", stStringEnd)
+	setAnyTrans(stStringEsc, stString)
 
 	// Int
 	setTrans(stStart, oneToNine, stInt)
@@ -158,11 +236,22 @@ func init() {
 	setTrans(stZero, octalDigits, stOctInt)
 	setTrans(stOctInt, octalDigits, stOctInt)
 
+	// Unsigned
+	for _, st := range []state{stInt, stZero, stBinInt, stHexInt, stOctInt} {
+		setTrans(st, "u", stUint)
+	}
+
 	// Float
 	setTrans(stInt, ".", stFloat)
 	setTrans(stFloat, digits, stFloat)
 
+	// Imaginary, which like Go treats a leading zero as decimal
+	for _, st := range []state{stInt, stZero, stOctInt, stFloat} {
+		setTrans(st, "i", stImaginary)
+	}
+
 	// Comparisons
+	setTrans(stEqual, "=", stEqualEqual)
 	setTrans(stStart, "!", stLogicalNot)
 	setTrans(stLogicalNot, "=", stNotEqual)
 	setTrans(stStart, "<", stLessThan)
@@ -175,58 +264,64 @@ func init() {
 	setTrans(stStart, "|", stBitwiseOr)
 	setTrans(stBitwiseOr, "|", stLogicalOr)
 
+	// Lambda arrow
+	setTrans(stEqual, ">", stArrow)
+
+	// Assignment
+	setTrans(stColon, "=", stAssign)
+
 	// Shift
 	setTrans(stLessThan, "<", stLeftShift)
 	setTrans(stGreaterThan, ">", stRightShift)
-
-	// Boolean literals
-	setTrans(stStart, "t", stT)
-	setTrans(stT, "r", stTr)
-	setTrans(stTr, "u", stTru)
-	setTrans(stTru, "e", stTrue)
-	setTrans(stTrue, letters+digits, stId)
-
-	setTrans(stStart, "f", stF)
-	setTrans(stF, "a", stFa)
-	setTrans(stFa, "l", stFal)
-	setTrans(stFal, "s", stFals)
-	setTrans(stFals, "e", stFalse)
-	setTrans(stFalse, letters+digits, stId)
 }
 
-func newLexer(input string) lexer {
+func newLexer(input string, d *Dialect) lexer {
 	return &gocalcLexer{
-		input:  input,
-		tokens: queue{},
-		state:  stStart,
+		input:   input,
+		tokens:  queue{},
+		state:   stStart,
+		dialect: d,
 	}
 }
 
 func (l *gocalcLexer) push() {
-	if len(l.input) == 0 {
+	if l.state == stStart && l.pos >= len(l.input) {
 		l.emit(tokenEOF)
 		return
 	}
 
 	for len(l.tokens) < 1 {
+		if l.state == stStart {
+			// The dialect's symbols take precedence over the built-in tokens
+			// they begin with.
+			if o := l.dialect.prefix(l.input[l.pos:]); o != nil {
+				l.pos += len(o.symbol)
+				l.emit(tokenOperator)
+				continue
+			}
+		}
+
 		nextState := stErr
 
 		if l.pos < len(l.input) {
-			nextState = trans[l.state][l.input[l.pos]]
+			nextState = l.next()
 		}
 
 		if nextState == stErr {
 			curTokenType := stateTokens[l.state]
 			if curTokenType == tokenError {
-				for l.pos < len(l.input) && trans[l.state][l.input[l.pos]] == stErr {
-					l.pos++
+				for l.pos < len(l.input) && l.next() == stErr && l.dialect.prefix(l.input[l.pos:]) == nil {
+					l.pos += l.width
 				}
 				l.emit(tokenError)
+				l.state = stStart
 				return
 			}
 
 			if curTokenType != tokenWhitespace {
 				l.emit(curTokenType)
+			} else if strings.Contains(l.input[l.start:l.pos], "\n") {
+				l.newline = true
 			}
 			l.start = l.pos
 			l.state = stStart
@@ -236,11 +331,19 @@ func (l *gocalcLexer) push() {
 			}
 		} else {
 			l.state = nextState
-			l.pos++
+			l.pos += l.width
 		}
 	}
 }
 
+// next decodes the rune at the current position, storing its width, and
+// returns the state it transitions to.
+func (l *gocalcLexer) next() state {
+	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.width = w
+	return trans[l.state][class(r)]
+}
+
 func (l *gocalcLexer) token() *token {
 	if len(l.tokens) < 1 {
 		l.push()
@@ -258,20 +361,37 @@ func (l *gocalcLexer) peekToken() *token {
 const eof = -1
 
 type gocalcLexer struct {
-	input  string
-	start  int
-	pos    int
-	width  int
-	tokens queue
-	state  state
+	input   string
+	start   int
+	pos     int
+	width   int
+	tokens  queue
+	state   state
+	newline bool     // whether the whitespace before the next token has a newline
+	dialect *Dialect // the operators added to the built-in ones, if any
 }
 
 func (l *gocalcLexer) emit(t tokenType) {
+	val := l.input[l.start:l.pos]
+	var op *operator
+	switch t {
+	case tokenIdentifier:
+		if k, ok := keywords[val]; ok {
+			t = k
+		} else if op = l.dialect.lookup(val); op != nil {
+			t = tokenOperator
+		}
+	case tokenOperator:
+		op = l.dialect.lookup(val)
+	}
 	l.tokens.push(&token{
-		typ: t,
-		val: l.input[l.start:l.pos],
-		pos: l.start,
-		end: l.pos,
+		typ:     t,
+		val:     val,
+		pos:     l.start,
+		end:     l.pos,
+		newline: l.newline,
+		op:      op,
 	})
 	l.start = l.pos
+	l.newline = false
 }
@@ -32,16 +32,29 @@ var lexerSingleTokenTests = []lexerSingleTokenTest{
 	{true, "0b10", tokenInt, "0b10"},
 	{false, "0b3", tokenError, ""},
 	{false, "0b", tokenError, ""},
-	{false, "0b1g", tokenError, ""},
 	{true, "077", tokenInt, "077"},
-	{false, "08", tokenError, ""},
 	{true, "0", tokenInt, "0"},
-	{false, ".", tokenError, ""},
-	{false, "5..", tokenError, ""},
-	{false, "3..5", tokenError, ""},
 	{true, "0.", tokenFloat, "0."},
+	{true, "42u", tokenUint, "42u"},
+	{true, "0u", tokenUint, "0u"},
+	{true, "0xffu", tokenUint, "0xffu"},
+	{true, "0b10u", tokenUint, "0b10u"},
+	{true, "3i", tokenImaginary, "3i"},
+	{true, "2.5i", tokenImaginary, "2.5i"},
+	{true, "0i", tokenImaginary, "0i"},
+	{true, "017i", tokenImaginary, "017i"},
 
 	{true, ",", tokenComma, ""},
+	{true, ".", tokenDot, ""},
+	{true, "[", tokenLeftBracket, ""},
+	{true, "]", tokenRightBracket, ""},
+
+	{true, `""`, tokenString, `""`},
+	{true, `"env"`, tokenString, `"env"`},
+	{true, `"a \"b\" \\ c"`, tokenString, `"a \"b\" \\ c"`},
+	{true, `"größe €"`, tokenString, `"größe €"`},
+	{false, `"abc`, tokenError, ""},
+	{false, `"abc\"`, tokenError, ""},
 	{true, "(", tokenLeftParen, ""},
 	{true, ")", tokenRightParen, ""},
 
@@ -57,6 +70,7 @@ var lexerSingleTokenTests = []lexerSingleTokenTest{
 	{true, ">", tokenGreaterThan, ""},
 	{true, ">=", tokenGreaterOrEqual, ""},
 	{true, "=", tokenEqual, ""},
+	{true, "==", tokenEqual, ""},
 	{true, "!=", tokenNotEqual, ""},
 	{true, "&", tokenBitwiseAnd, ""},
 	{true, "^", tokenBitwiseXor, ""},
@@ -67,9 +81,34 @@ var lexerSingleTokenTests = []lexerSingleTokenTest{
 
 	{true, "x", tokenIdentifier, "x"},
 	{true, "true", tokenTrue, ""},
+	{true, "and", tokenLogicalAnd, "and"},
+	{true, "or", tokenLogicalOr, "or"},
+	{true, "not", tokenNot, "not"},
+	{true, "between", tokenBetween, "between"},
+	{true, "android", tokenIdentifier, "android"},
 	{true, "false", tokenFalse, ""},
+	{true, "null", tokenNull, ""},
+	{true, "is", tokenIs, ""},
+	{true, "in", tokenIn, ""},
+	{true, "tru", tokenIdentifier, "tru"},
+	{true, "truest", tokenIdentifier, "truest"},
+	{true, "nullable", tokenIdentifier, "nullable"},
+	{true, "is_set", tokenIdentifier, "is_set"},
+	{true, "t", tokenIdentifier, "t"},
+	{true, "tx", tokenIdentifier, "tx"},
+	{true, "fals", tokenIdentifier, "fals"},
+	{true, "true1", tokenIdentifier, "true1"},
+	{true, "_", tokenIdentifier, "_"},
+	{true, "rate_2024", tokenIdentifier, "rate_2024"},
+	{true, "größe", tokenIdentifier, "größe"},
+	{true, "δt", tokenIdentifier, "δt"},
+	{true, "x٣", tokenIdentifier, "x٣"},
+	{true, "日本", tokenIdentifier, "日本"},
+	{false, "٣x", tokenError, ""},
+	{false, "x²", tokenError, ""},
+	{false, "€", tokenError, ""},
+	{false, "\xff", tokenError, ""},
 
-	{false, "3a", tokenError, ""},
 	{false, "0x", tokenError, ""},
 	{false, "`", tokenError, ""},
 }
@@ -82,8 +121,30 @@ var lexerMultipleTokenTests = []lexerMultipleTokenTest{
 	{true, "5*1/2", types(tokenInt, tokenStar, tokenInt, tokenSlash, tokenInt),
 		vals("5", "", "1", "", "2")},
 
+	{true, "3a", types(tokenInt, tokenIdentifier), vals("3", "a")},
+	{true, "0b1g", types(tokenInt, tokenIdentifier), vals("0b1", "g")},
+	{true, "08", types(tokenInt, tokenInt), vals("0", "8")},
+	{true, "1.5u", types(tokenFloat, tokenIdentifier), vals("1.5", "u")},
+	{true, "2ii", types(tokenImaginary, tokenIdentifier), vals("2i", "i")},
+	{true, "0x1i", types(tokenInt, tokenIdentifier), vals("0x1", "i")},
+	{true, "1-2i", types(tokenInt, tokenMinus, tokenImaginary), vals("1", "", "2i")},
+
+	{true, "δt+größe", types(tokenIdentifier, tokenPlus, tokenIdentifier), vals("δt", "", "größe")},
+	{true, "f(order.total, _x)", types(tokenIdentifier, tokenLeftParen, tokenIdentifier, tokenDot, tokenIdentifier, tokenComma, tokenIdentifier, tokenRightParen),
+		vals("f", "", "order", "", "total", "", "_x", "")},
+	{true, "3..5", types(tokenFloat, tokenDot, tokenInt), vals("3.", "", "5")},
+	{true, `items[0].price + tags["env"]`, types(tokenIdentifier, tokenLeftBracket, tokenInt, tokenRightBracket, tokenDot, tokenIdentifier,
+		tokenPlus, tokenIdentifier, tokenLeftBracket, tokenString, tokenRightBracket),
+		vals("items", "", "0", "", "", "price", "", "tags", "", `"env"`, "")},
+
 	{true, "f(x)", types(tokenIdentifier, tokenLeftParen, tokenIdentifier, tokenRightParen),
 		vals("f", "", "x", "")},
+	{true, "x=>x >= 1", types(tokenIdentifier, tokenArrow, tokenIdentifier, tokenGreaterOrEqual, tokenInt),
+		vals("x", "", "x", "", "1")},
+	{true, "x := 1;y", types(tokenIdentifier, tokenAssign, tokenInt, tokenSemicolon, tokenIdentifier),
+		vals("x", "", "1", "", "y")},
+	{true, `{a: 1}`, types(tokenLeftBrace, tokenIdentifier, tokenColon, tokenInt, tokenRightBrace),
+		vals("", "a", "", "1", "")},
 }
 
 func multipleTokenTest(test lexerSingleTokenTest) lexerMultipleTokenTest {
@@ -117,7 +178,7 @@ func TestLexCountMallocs(t *testing.T) {
 
 	s := "((((1) + (2) - (3) & (4)) * (5) / (1.)) >= (2)) && ((((5) - (4) * (3)) / (2)) <= (1))"
 	mallocs := testing.AllocsPerRun(100, func() {
-		l := newLexer(s)
+		l := newLexer(s, nil)
 		for l.token().typ != tokenEOF {
 		}
 	})
@@ -128,7 +189,7 @@ func TestLexCountMallocs(t *testing.T) {
 func BenchmarkLexConstantExpression(b *testing.B) {
 	s := "((((1) + (2) - (3) & (4)) * (5) / (1.)) >= (2)) && ((((5) - (4) * (3)) / (2)) <= (1))"
 	for i := 0; i < b.N; i++ {
-		l := newLexer(s)
+		l := newLexer(s, nil)
 		t := l.token()
 		for t.typ != tokenEOF {
 			t = l.token()
@@ -142,7 +203,7 @@ func shouldLex(test lexerMultipleTokenTest, t *testing.T) {
 	s := test.input
 	ts := test.types
 	v := test.vals
-	l := newLexer(s)
+	l := newLexer(s, nil)
 	for i, e := range ts {
 		to := l.token()
 		if to.typ != e {
@@ -161,7 +222,7 @@ func shouldLex(test lexerMultipleTokenTest, t *testing.T) {
 }
 
 func lexShouldFail(s string, t *testing.T) {
-	l := newLexer(s)
+	l := newLexer(s, nil)
 	f := false
 	ts := []*token{}
 
@@ -177,6 +238,8 @@ func lexShouldFail(s string, t *testing.T) {
 		if to.typ == tokenError {
 			f = true
 			break
+		} else if to.typ == tokenEOF {
+			break
 		}
 	}
 
@@ -0,0 +1,286 @@
+package gocalc
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Operators used by builtins to combine values like the expression would.
+var (
+	plusOp = &token{typ: tokenPlus, val: "+"}
+	lessOp = &token{typ: tokenLessThan, val: "<"}
+	quoOp  = &token{typ: tokenSlash, val: "/"}
+	starOp = &token{typ: tokenStar, val: "*"}
+)
+
+// list returns x, the argument of f, as a list.
+func (e *evaluator) list(f *funcExpr, x interface{}) []interface{} {
+	l, ok := x.([]interface{})
+	if !ok {
+		e.error("%s requires a list, got %v (%T)", f.function, x, x)
+	}
+	return l
+}
+
+// values returns the values aggregated by f: the elements of its argument
+// if that is a single list, or else its arguments. As in SQL, nulls are
+// left out.
+func (e *evaluator) values(f *funcExpr, args []interface{}) []interface{} {
+	if len(args) == 1 {
+		if l, ok := args[0].([]interface{}); ok {
+			args = l
+		}
+	}
+	values := make([]interface{}, 0, len(args))
+	for _, v := range args {
+		if v != Null {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// less reports whether the number or string a orders before b.
+func (e *evaluator) less(a, b interface{}) bool {
+	r, ok := e.binary(lessOp, a, b).(bool)
+	if !ok {
+		e.error("Cannot order %v (%T) and %v (%T)", a, a, b, b)
+	}
+	return r
+}
+
+// len(x) returns the number of elements of the list x, entries of the map
+// x, or characters of the string x.
+func builtinLen(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	var n int
+	switch r := args[0].(type) {
+	case []interface{}:
+		n = len(r)
+	case map[string]interface{}:
+		n = len(r)
+	case string:
+		n = utf8.RuneCountInString(r)
+	default:
+		e.error("len requires a list, map or string, got %v (%T)", r, r)
+	}
+	return e.adapt(int64(n))
+}
+
+// sum(list) or sum(x, ...) returns the sum of the values, which is 0 if
+// there are none.
+func builtinSum(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	values := e.values(f, args)
+	if len(values) == 0 {
+		return e.adapt(int64(0))
+	}
+	sum := e.zero(values[0])
+	for _, v := range values {
+		sum = e.binary(plusOp, sum, v)
+	}
+	return sum
+}
+
+// zero returns the zero that sums of values like x start from: a Quantity
+// of zero in the unit of x, no time if x is a duration, or else 0.
+func (e *evaluator) zero(x interface{}) interface{} {
+	switch x := x.(type) {
+	case Quantity:
+		return Quantity{e.adapt(int64(0)), x.Unit}
+	case time.Duration:
+		return time.Duration(0)
+	}
+	return e.adapt(int64(0))
+}
+
+// avg(list) or avg(x, ...) returns the mean of the values, which is null if
+// there are none.
+func builtinAvg(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	values := e.values(f, args)
+	if len(values) == 0 {
+		return Null
+	}
+	sum := builtinSum(e, f, values)
+	if !e.config.decimal {
+		// Unlike integer division, the mean of 1 and 2 is 1.5.
+		if q, ok := sum.(Quantity); ok {
+			q.Value = e.fractional(q.Value)
+			sum = q
+		} else {
+			sum = e.fractional(sum)
+		}
+	}
+	return e.binary(quoOp, sum, e.adapt(int64(len(values))))
+}
+
+// fractional returns x as a float if it is an integer, or else x.
+func (e *evaluator) fractional(x interface{}) interface{} {
+	if r := rank(x); r == rankInt || r == rankUint {
+		return e.convert(x, rankFloat)
+	}
+	return x
+}
+
+// min(list) or min(x, ...) returns the least of the values, which is null
+// if there are none.
+func builtinMin(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.extreme(f, args, true)
+}
+
+// max(list) or max(x, ...) returns the greatest of the values, which is
+// null if there are none.
+func builtinMax(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.extreme(f, args, false)
+}
+
+func (e *evaluator) extreme(f *funcExpr, args []interface{}, min bool) interface{} {
+	values := e.values(f, args)
+	if len(values) == 0 {
+		return Null
+	}
+	r := values[0]
+	for _, v := range values[1:] {
+		if e.less(v, r) == min && !e.equal(v, r) {
+			r = v
+		}
+	}
+	return r
+}
+
+// count(list) or count(x, ...) returns the number of values that are not
+// null.
+func builtinCount(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.adapt(int64(len(e.values(f, args))))
+}
+
+// contains(c, x) reports whether x is an element of the list c, a key of
+// the map c, or a substring of the string c, like x in c.
+func builtinContains(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	r := e.in(args[1], args[0])
+	if r == nil {
+		e.error("contains requires a list, map or string, got %v (%T)", args[0], args[0])
+	}
+	return r
+}
+
+// any(list) reports whether any element of a list of booleans is true, and
+// any(list, fn) whether fn returns true for any element. As with ||, the
+// result is null if none is true but some are null.
+func builtinAny(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.quantify(f, args, true)
+}
+
+// all(list) reports whether every element of a list of booleans is true,
+// and all(list, fn) whether fn returns true for every element. As with &&,
+// the result is null if none is false but some are null.
+func builtinAll(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.quantify(f, args, false)
+}
+
+// quantify returns whether any of the booleans given by args is true if
+// want is true, or whether all are if want is false.
+func (e *evaluator) quantify(f *funcExpr, args []interface{}, want bool) interface{} {
+	l := e.list(f, args[0])
+	var c *closure
+	if len(args) > 1 {
+		c = e.closure(f, args[1])
+	}
+
+	var r interface{} = !want
+	for _, v := range l {
+		if c != nil {
+			v = e.call(c, v)
+		}
+		switch v {
+		case want:
+			return want
+		case Null:
+			r = Null
+		case !want:
+		default:
+			e.error("%s requires booleans, got %v (%T)", f.function, v, v)
+		}
+	}
+	return r
+}
+
+// sort(list) returns a copy of list in ascending order, with nulls first.
+func builtinSort(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	l := e.list(f, args[0])
+	return e.order(l, l)
+}
+
+// distinct(list) returns the elements of list without duplicates, in the
+// order of their first occurrence.
+func builtinDistinct(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	r := []interface{}{}
+	for _, v := range e.list(f, args[0]) {
+		if e.in(v, r) == false {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// slice(x, start) or slice(x, start, end) returns the elements of the list
+// x, or characters of the string x, from start up to but excluding end. A
+// negative index counts from the end, and indexes beyond either end are
+// clamped to it.
+func builtinSlice(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	var n int
+	switch r := args[0].(type) {
+	case []interface{}:
+		n = len(r)
+	case string:
+		n = utf8.RuneCountInString(r)
+	default:
+		e.error("slice requires a list or string, got %v (%T)", r, r)
+	}
+
+	start, end := e.intArg(f, args, 1), n
+	if len(args) > 2 {
+		end = e.intArg(f, args, 2)
+	}
+	start, end = clamp(start, n), clamp(end, n)
+	if end < start {
+		end = start
+	}
+
+	if s, ok := args[0].(string); ok {
+		return string([]rune(s)[start:end])
+	}
+	return append([]interface{}{}, args[0].([]interface{})[start:end]...)
+}
+
+// clamp converts the possibly negative index i into the range [0, n].
+func clamp(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// concat(x, ...) joins lists into one list, or strings into one string.
+func builtinConcat(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if s, ok := args[0].(string); ok {
+		for _, arg := range args[1:] {
+			r, ok := arg.(string)
+			if !ok {
+				e.error("concat requires all strings, got %v (%T)", arg, arg)
+			}
+			s += r
+		}
+		return s
+	}
+
+	r := []interface{}{}
+	for _, arg := range args {
+		r = append(r, e.list(f, arg)...)
+	}
+	return r
+}
@@ -0,0 +1,106 @@
+package gocalc
+
+import (
+	"fmt"
+	"testing"
+)
+
+var listTests = []expressionTest{
+	{true, "[]", []interface{}{}},
+	{true, "[1, 2.5, \"a\", true, null]", []interface{}{int64(1), 2.5, "a", true, Null}},
+	{true, "[1 + 1, [2]][1][0]", 2},
+	{true, "2 in [1, 2, 3]", true},
+	{true, "2.0 in [1, 2, 3]", true},
+	{true, "4 in [1, 2, 3]", false},
+	{true, "1 + 1 in [2] && true", true},
+	{true, "\"ell\" in \"hello\"", true},
+	{true, "\"go\" in tags", true},
+	{true, "[1, [2]] = [1.0, [2]]", true},
+	{true, "[1, 2] != [1]", true},
+	{true, "\"a\" < \"b\"", true},
+	{true, "len(tags) > 0", true},
+	{true, "len(\"größe\")", 5},
+	{true, "len([])", 0},
+	{true, "sum(prices)", 7.5},
+	{true, "sum(1, 2, 3)", 6},
+	{true, "sum([])", 0},
+	{true, "sum([1, null, 2])", 3},
+	{true, "avg([1, 2])", 1.5},
+	{true, "avg(prices)", 2.5},
+	{true, "avg([])", Null},
+	{true, "min(prices)", 1.5},
+	{true, "max(3, 1, 2)", 3},
+	{true, "max(tags)", "go"},
+	{true, "min([])", Null},
+	{true, "count([1, null, 3])", 2},
+	{true, "contains(tags, \"calc\")", true},
+	{true, "contains(\"hello\", \"z\")", false},
+	{true, "any([false, true])", true},
+	{true, "any([false, null])", Null},
+	{true, "all([true, true])", true},
+	{true, "all([true, null, false])", false},
+	{true, "all([])", true},
+	{true, "sort([3, 1.5, null, 2])", []interface{}{Null, 1.5, int64(2), int64(3)}},
+	{true, "sort(tags)", []interface{}{"calc", "go"}},
+	{true, "distinct([1, 2, 1, 1.0, 3])", []interface{}{int64(1), int64(2), int64(3)}},
+	{true, "slice([1, 2, 3, 4], 1, 3)", []interface{}{int64(2), int64(3)}},
+	{true, "slice([1, 2, 3, 4], -2)", []interface{}{int64(3), int64(4)}},
+	{true, "slice([1, 2, 3], 5)", []interface{}{}},
+	{true, "slice(\"größe\", 1, 3)", "rö"},
+	{true, "concat([1], [2, 3], [])", []interface{}{int64(1), int64(2), int64(3)}},
+	{true, "concat(\"a\", \"b\")", "ab"},
+	{true, "sum(null)", Null},
+	{false, "1 in 2", nil},
+	{false, "sort([1, \"a\"])", nil},
+	{false, "len(1)", nil},
+	{false, "any([1])", nil},
+	{false, "concat(\"a\", [1])", nil},
+	{false, "[1] + [2]", nil},
+	{false, "[1][2]", nil},
+}
+
+func TestList(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"prices": []float64{1.5, 2.5, 3.5},
+		"tags":   []string{"go", "calc"},
+	})
+
+	for _, test := range listTests {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestListFuncHandlerFirst(t *testing.T) {
+	e, err := NewExpr("sum([1, 2])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := e.Evaluate(nil, func(fn string, args ...func() interface{}) (interface{}, bool) {
+		return "handled", fn == "sum"
+	})
+	if err != nil || res != "handled" {
+		t.Errorf("Evaluation returned %v, %v, expected the FuncHandler's result", res, err)
+	}
+}
+
+func TestListModes(t *testing.T) {
+	for _, test := range []struct {
+		opt    Option
+		expr   string
+		expect string
+	}{
+		{WithDecimal(2, RoundHalfEven), "avg([1, 2, 2])", "1.67"},
+		{WithDecimal(2, RoundHalfEven), "sum(prices)", "7.5"},
+		{WithBigNumbers(false), "avg([1, 2, 2])", "5/3"},
+		{WithBigNumbers(false), "len([1]) + (1 << 64)", "18446744073709551617"},
+	} {
+		e, err := NewExpr(test.expr, test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := e.Evaluate(MapEnv(map[string]interface{}{"prices": []float64{1.5, 2.5, 3.5}}), nil)
+		if s := fmt.Sprint(res); err != nil || s != test.expect {
+			t.Errorf("Expression \"%v\": Evaluation returned %v (%T), %v, expected %v", test.expr, res, res, err, test.expect)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package gocalc
+
+import "sort"
+
+// dict returns x, the argument of f, as a map.
+func (e *evaluator) dict(f *funcExpr, x interface{}) map[string]interface{} {
+	m, ok := x.(map[string]interface{})
+	if !ok {
+		e.error("%s requires a map, got %v (%T)", f.function, x, x)
+	}
+	return m
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keys(m) returns a list of the keys of m in ascending order.
+func builtinKeys(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	keys := sortedKeys(e.dict(f, args[0]))
+	r := make([]interface{}, len(keys))
+	for i, k := range keys {
+		r[i] = k
+	}
+	return r
+}
+
+// values(m) returns a list of the values of m, in the order of their keys.
+func builtinValues(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	m := e.dict(f, args[0])
+	keys := sortedKeys(m)
+	r := make([]interface{}, len(keys))
+	for i, k := range keys {
+		r[i] = m[k]
+	}
+	return r
+}
+
+// has(m, k) reports whether m has the key k.
+func builtinHas(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	m := e.dict(f, args[0])
+	k, ok := args[1].(string)
+	if !ok {
+		e.error("has requires a string key, got %v (%T)", args[1], args[1])
+	}
+	_, found := m[k]
+	return found
+}
+
+// get(m, k) or get(m, k, default) returns the value of m for the key k, or
+// default if there is none. The default default is null. Unlike m and k,
+// default may be null without making the result null.
+func builtinGet(e *evaluator, f *funcExpr) interface{} {
+	args := e.args(f, 2, 3)
+	if args[0] == Null || args[1] == Null {
+		return Null
+	}
+	if builtinHas(e, f, args[:2]) == true {
+		return e.dict(f, args[0])[args[1].(string)]
+	}
+	if len(args) > 2 {
+		return args[2]
+	}
+	return Null
+}
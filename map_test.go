@@ -0,0 +1,75 @@
+package gocalc
+
+import "testing"
+
+var mapTests = []expressionTest{
+	{true, "{}", map[string]interface{}{}},
+	{true, `{a: 1, "b c": [2], d: {e: null}}`, map[string]interface{}{"a": int64(1), "b c": []interface{}{int64(2)}, "d": map[string]interface{}{"e": Null}}},
+	{true, `{gold: 0.2}["gold"]`, 0.2},
+	{true, "{gold: 0.2}.gold", 0.2},
+	{true, "{a: {b: 1 + 1}}.a.b", 2},
+	{true, "rates.gold * 10", 2.0},
+	{true, `rates["silver"]`, 0.1},
+	{true, `"gold" in rates`, true},
+	{true, `"bronze" in rates`, false},
+	{true, "{a: 1, b: [2]} = {b: [2.0], a: 1}", true},
+	{true, "{a: 1} = {a: 1, b: 2}", false},
+	{true, "{a: 1} != {b: 1}", true},
+	{true, "len(rates)", 2},
+	{true, "keys({b: 1, a: 2})", []interface{}{"a", "b"}},
+	{true, "values({b: 1, a: 2})", []interface{}{int64(2), int64(1)}},
+	{true, `has(rates, "gold")`, true},
+	{true, `has(rates, "bronze")`, false},
+	{true, `get(rates, "gold")`, 0.2},
+	{true, `get(rates, "bronze", 0)`, 0},
+	{true, `get(rates, "bronze")`, Null},
+	{true, `get(rates, "gold", null)`, 0.2},
+	{true, `get(rates, "bronze", null)`, Null},
+	{true, `get(null, "gold", 0)`, Null},
+	{true, `get(rates, null, 0)`, Null},
+	{true, `contains(rates, "silver")`, true},
+	{true, "keys(null)", Null},
+	{false, "rates.bronze", nil},
+	{false, `rates["bronze"]`, nil},
+	{false, "keys([1])", nil},
+	{false, "has(rates, 1)", nil},
+	{false, "{a: 1} + 1", nil},
+}
+
+func TestMap(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"rates": map[string]float64{"gold": 0.2, "silver": 0.1},
+	})
+
+	for _, test := range mapTests {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestMapMissingAsNull(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"rates": map[string]float64{"gold": 0.2},
+	})
+
+	for _, expr := range []string{"rates.bronze", `rates["bronze"]`, "{a: 1}.b"} {
+		e, err := NewExpr(expr, WithMissingAsNull())
+		if err != nil {
+			t.Fatalf("Expression \"%v\": %v", expr, err)
+		}
+		if res, err := e.Evaluate(params, nil); err != nil || res != Null {
+			t.Errorf("Expression \"%v\": Evaluation returned %v, %v, expected null", expr, res, err)
+		}
+	}
+}
+
+func TestMapDecimalValues(t *testing.T) {
+	e, _ := NewExpr("rates.gold + 0.1", WithDecimal(2, RoundHalfEven))
+	params := MapEnv(map[string]interface{}{
+		"rates": map[string]float64{"gold": 0.2},
+	})
+
+	res, err := e.Evaluate(params, nil)
+	if d, ok := res.(Decimal); err != nil || !ok || d.String() != "0.3" {
+		t.Errorf("Evaluation returned %v (%T), %v, expected Decimal 0.3", res, res, err)
+	}
+}
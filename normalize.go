@@ -0,0 +1,302 @@
+package gocalc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// A Valuer is a caller-defined type that converts itself to a value the
+// evaluator understands. Resolvers, FuncHandlers and fields of structured
+// parameters may all return Valuers.
+//
+type Valuer interface {
+	CalcValue() (interface{}, error)
+}
+
+// maxValuerDepth bounds how many Valuers may wrap one another, so that a
+// Valuer returning itself cannot recurse forever.
+const maxValuerDepth = 16
+
+// maxNestingDepth bounds how deeply lists and maps may be nested within a
+// value, so that a map or slice containing itself cannot recurse forever.
+const maxNestingDepth = 64
+
+// normalize converts a value entering the evaluator to the evaluator's own
+// types, panicking with an EvaluationError if that is not possible. Floats
+// become Decimals when the Expression was compiled with WithDecimal, and
+// integers *big.Ints when it was compiled with WithBigNumbers.
+func (e *evaluator) normalize(v interface{}) interface{} {
+	r, err := normalize(v, e.config.big)
+	if err != nil {
+		e.error("%s", err)
+	}
+	return e.adapt(r)
+}
+
+// adapt converts the numbers in the normalized value v, including the
+// elements of lists, to the numeric mode of the Expression.
+func (e *evaluator) adapt(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		if e.config.decimal {
+			return e.decimal(n)
+		}
+	case int64:
+		if e.config.big {
+			return big.NewInt(n)
+		}
+	// Lists and maps may be the caller's own, so they are copied before
+	// any element is changed.
+	case []interface{}:
+		var r []interface{}
+		for i, elem := range n {
+			a := e.adapt(elem)
+			if r == nil && !unchanged(elem, a) {
+				r = append(make([]interface{}, 0, len(n)), n[:i]...)
+			}
+			if r != nil {
+				r = append(r, a)
+			}
+		}
+		if r != nil {
+			return r
+		}
+	case map[string]interface{}:
+		var r map[string]interface{}
+		for k, elem := range n {
+			a := e.adapt(elem)
+			if r == nil && !unchanged(elem, a) {
+				r = make(map[string]interface{}, len(n))
+				for k, elem := range n {
+					r[k] = elem
+				}
+			}
+			if r != nil {
+				r[k] = a
+			}
+		}
+		if r != nil {
+			return r
+		}
+	case Quantity:
+		n.Value = e.adapt(n.Value)
+		return n
+	}
+	return v
+}
+
+// normalize converts v to the types used by the evaluator: every integer
+// type becomes int64, every float type float64 and every complex type
+// complex128, including named types such as `type Celsius float64`.
+// Unsigned values that do not fit in an int64 become uint64, and big.Ints
+// that do not fit are an error rather than silently wrapping. If bigInts is
+// true, both become *big.Int instead. Slices and arrays become
+// []interface{} lists of normalized elements, and maps with string keys
+// map[string]interface{}, in both of which nil is Null; a list or map that
+// is already normalized is returned as it is. A time.Duration is
+// left as it is, rather than becoming an int64, as are values implementing
+// any of the operator interfaces, while the value of a Quantity is
+// normalized in turn.
+func normalize(v interface{}, bigInts bool) (interface{}, error) {
+	return normalizeNested(v, bigInts, 0)
+}
+
+// normalizeNested normalizes v, which is nested depth lists or maps deep.
+func normalizeNested(v interface{}, bigInts bool, depth int) (interface{}, error) {
+	if depth > maxNestingDepth {
+		return nil, fmt.Errorf("Value %T nested too deeply", v)
+	}
+
+	for wraps := 0; ; wraps++ {
+		valuer, ok := v.(Valuer)
+		if !ok {
+			break
+		}
+		if wraps == maxValuerDepth {
+			return nil, fmt.Errorf("Valuer %T nested too deeply", valuer)
+		}
+
+		var err error
+		if v, err = valuer.CalcValue(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch r := v.(type) {
+	case nil, int64, float64, complex128, bool, string:
+		return v, nil
+	case int:
+		return int64(r), nil
+	case float32:
+		return float64(r), nil
+	case complex64:
+		return complex128(r), nil
+	case json.Number:
+		if bigInts {
+			if i, ok := new(big.Int).SetString(string(r), 10); ok {
+				return i, nil
+			}
+		}
+		if i, err := r.Int64(); err == nil {
+			return i, nil
+		}
+		if f, err := r.Float64(); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("Invalid number %q", r)
+	case *big.Int:
+		if r == nil {
+			return nil, nil
+		}
+		if bigInts {
+			return r, nil
+		}
+		if !r.IsInt64() {
+			return nil, fmt.Errorf("Value %s overflows int64", r)
+		}
+		return r.Int64(), nil
+	case *big.Rat:
+		if r == nil {
+			return nil, nil
+		}
+		return r, nil
+	case time.Duration:
+		return r, nil
+	case *time.Time:
+		if r == nil {
+			return nil, nil
+		}
+		return *r, nil
+	case Quantity:
+		val, err := normalizeNested(r.Value, bigInts, depth)
+		if err != nil {
+			return nil, err
+		}
+		return Quantity{val, r.Unit}, nil
+	case *Quantity:
+		if r == nil {
+			return nil, nil
+		}
+		return normalizeNested(*r, bigInts, depth)
+	case *Interval:
+		if r == nil {
+			return nil, nil
+		}
+		return *r, nil
+	case Adder, Multiplier, Comparer, Negater:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		switch {
+		case u <= math.MaxInt64:
+			return int64(u), nil
+		case bigInts:
+			return new(big.Int).SetUint64(u), nil
+		}
+		return u, nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Complex64, reflect.Complex128:
+		return rv.Complex(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice, reflect.Array:
+		// A []interface{} is only copied once one of its elements changes.
+		src, _ := v.([]interface{})
+		var list []interface{}
+		for i := 0; i < rv.Len(); i++ {
+			x := rv.Index(i).Interface()
+			elem, err := normalizeNested(x, bigInts, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if elem == nil {
+				elem = Null
+			}
+			if list == nil && (src == nil || !unchanged(x, elem)) {
+				list = append(make([]interface{}, 0, rv.Len()), src[:i]...)
+			}
+			if list != nil {
+				list = append(list, elem)
+			}
+		}
+		if list == nil {
+			if src != nil {
+				return src, nil
+			}
+			list = []interface{}{}
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		src, _ := v.(map[string]interface{})
+		var m map[string]interface{}
+		for it := rv.MapRange(); it.Next(); {
+			x := it.Value().Interface()
+			elem, err := normalizeNested(x, bigInts, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if elem == nil {
+				elem = Null
+			}
+			if m == nil && (src == nil || !unchanged(x, elem)) {
+				m = make(map[string]interface{}, rv.Len())
+				for k, elem := range src {
+					m[k] = elem
+				}
+			}
+			if m != nil {
+				m[it.Key().String()] = elem
+			}
+		}
+		if m == nil {
+			if src != nil {
+				return src, nil
+			}
+			m = map[string]interface{}{}
+		}
+		return m, nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+	return v, nil
+}
+
+// unchanged reports whether r, which normalizing or adapting v returned, is
+// v itself. Only lists, maps and Quantities can change without changing
+// their type.
+func unchanged(v, r interface{}) bool {
+	if reflect.TypeOf(v) != reflect.TypeOf(r) {
+		return false
+	}
+	switch x := v.(type) {
+	case []interface{}:
+		y := r.([]interface{})
+		return len(x) == len(y) && (len(x) == 0 || &x[0] == &y[0])
+	case map[string]interface{}:
+		return reflect.ValueOf(x).Pointer() == reflect.ValueOf(r).Pointer()
+	case Quantity:
+		return unchanged(x.Value, r.(Quantity).Value)
+	}
+	return true
+}
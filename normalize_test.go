@@ -0,0 +1,183 @@
+package gocalc
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+type celsius float64
+type count uint8
+type flag bool
+type label string
+
+type money struct {
+	cents int64
+}
+
+func (m money) CalcValue() (interface{}, error) {
+	return float64(m.cents) / 100, nil
+}
+
+type wrapped struct {
+	v interface{}
+}
+
+func (w wrapped) CalcValue() (interface{}, error) {
+	return w.v, nil
+}
+
+type loop struct{}
+
+func (l loop) CalcValue() (interface{}, error) {
+	return l, nil
+}
+
+type broken struct{}
+
+func (b broken) CalcValue() (interface{}, error) {
+	return nil, errors.New("broken value")
+}
+
+var normalizeTests = []struct {
+	ok     bool
+	in     interface{}
+	expect interface{}
+}{
+	{true, nil, nil},
+	{true, int(-1), int64(-1)},
+	{true, int8(-8), int64(-8)},
+	{true, int16(16), int64(16)},
+	{true, int32(-32), int64(-32)},
+	{true, int64(64), int64(64)},
+	{true, uint(1), int64(1)},
+	{true, uint8(255), int64(255)},
+	{true, uint16(16), int64(16)},
+	{true, uint32(math.MaxUint32), int64(math.MaxUint32)},
+	{true, uint64(math.MaxInt64), int64(math.MaxInt64)},
+	{true, uintptr(3), int64(3)},
+	{true, uint64(math.MaxInt64 + 1), uint64(math.MaxInt64 + 1)},
+	{true, uint(math.MaxUint64), uint64(math.MaxUint64)},
+	{true, float32(0.5), 0.5},
+	{true, 2.5, 2.5},
+	{true, celsius(21.5), 21.5},
+	{true, count(3), int64(3)},
+	{true, flag(true), true},
+	{true, label("x"), "x"},
+	{true, json.Number("12"), int64(12)},
+	{true, json.Number("1.5e3"), 1500.0},
+	{true, json.Number("18446744073709551616"), 18446744073709551616.0},
+	{false, json.Number("abc"), nil},
+	{true, big.NewInt(-7), int64(-7)},
+	{false, new(big.Int).Lsh(big.NewInt(1), 64), nil},
+	{true, (*big.Int)(nil), nil},
+	{true, (*float64)(nil), nil},
+	{true, money{1250}, 12.5},
+	{true, wrapped{wrapped{uint16(2)}}, int64(2)},
+	{false, loop{}, nil},
+	{false, broken{}, nil},
+}
+
+func TestNormalize(t *testing.T) {
+	for _, test := range normalizeTests {
+		res, err := normalize(test.in, false)
+		if test.ok && err != nil {
+			t.Errorf("normalize(%v (%T)) failed with error: %v", test.in, test.in, err)
+		} else if test.ok && res != test.expect {
+			t.Errorf("normalize(%v (%T)) returned %v (%T), expected %v (%T)",
+				test.in, test.in, res, res, test.expect, test.expect)
+		} else if !test.ok && err == nil {
+			t.Errorf("normalize(%v (%T)) returned %v (%T) but should have failed", test.in, test.in, res, res)
+		}
+	}
+}
+
+func TestNormalizeNested(t *testing.T) {
+	list := []interface{}{int64(1), []interface{}{"a", Null}, map[string]interface{}{"b": 2.5}}
+	if r, err := normalize(list, false); err != nil || &r.([]interface{})[0] != &list[0] {
+		t.Errorf("normalize of a normalized list returned a copy %v, %v", r, err)
+	}
+	m := map[string]interface{}{"a": list}
+	if r, err := normalize(m, false); err != nil || reflect.ValueOf(r).Pointer() != reflect.ValueOf(m).Pointer() {
+		t.Errorf("normalize of a normalized map returned a copy %v, %v", r, err)
+	}
+
+	mixed := []interface{}{int64(1), int8(2), nil}
+	r, err := normalize(mixed, false)
+	if expect := []interface{}{int64(1), int64(2), Null}; err != nil || !reflect.DeepEqual(r, expect) {
+		t.Errorf("normalize(%v) returned %v, %v; expected %v", mixed, r, err, expect)
+	}
+	if mixed[1] != int8(2) || mixed[2] != nil {
+		t.Errorf("normalize modified its argument to %v", mixed)
+	}
+
+	cyclic := map[string]interface{}{}
+	cyclic["self"] = cyclic
+	if _, err := normalize(cyclic, false); err == nil {
+		t.Error("normalize of a map containing itself passed")
+	}
+	loop := make([]interface{}, 1)
+	loop[0] = loop
+	if _, err := normalize(loop, false); err == nil {
+		t.Error("normalize of a list containing itself passed")
+	}
+}
+
+func TestAdaptCopiesLists(t *testing.T) {
+	prices := []interface{}{1.5, int64(2)}
+	e, err := NewExpr("prices", WithDecimal(2, RoundHalfEven))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := e.Evaluate(MapEnv(map[string]interface{}{"prices": prices}), nil)
+	l, ok := res.([]interface{})
+	if err != nil || !ok || len(l) != 2 {
+		t.Fatalf("Evaluation returned %v, %v; expected a list", res, err)
+	}
+	if _, ok := l[0].(Decimal); !ok {
+		t.Errorf("Evaluation returned %v (%T) as the first element, expected a Decimal", l[0], l[0])
+	}
+	if prices[0] != 1.5 {
+		t.Errorf("Evaluation in decimal mode modified the parameter to %v", prices)
+	}
+}
+
+func TestNormalizedEvaluation(t *testing.T) {
+	params := map[string]interface{}{
+		"a":     int32(2),
+		"b":     uint16(3),
+		"c":     float32(0.5),
+		"n":     json.Number("4"),
+		"price": money{199},
+		"temps": []celsius{20, 22.5},
+		"huge":  uint64(math.MaxUint64),
+	}
+	funcs := func(fn string, args ...func() interface{}) (interface{}, bool) {
+		switch fn {
+		case "u8":
+			return uint8(args[0]().(int64)), true
+		case "cost":
+			return money{250}, true
+		}
+		return nil, false
+	}
+
+	tests := []expressionTest{
+		{true, "a * b + n", 10},
+		{true, "c * 4", 2.0},
+		{true, "price * 100", 199.0},
+		{true, "temps[1] - temps[0]", 2.5},
+		{true, "u8(300 - 50) + 1", 251},
+		{true, "cost() + price", 4.49},
+		{true, "huge", uint64(math.MaxUint64)},
+		{true, "huge - 1", uint64(math.MaxUint64 - 1)},
+		{false, "huge - -1", nil},
+	}
+
+	for _, test := range tests {
+		checkEvaluation(t, test, MapEnv(params), funcs)
+	}
+}
@@ -0,0 +1,39 @@
+package gocalc
+
+// NullValue is the type of Null.
+//
+type NullValue struct{}
+
+// Null is the value of the null literal, which marks a value that is known
+// to be missing. Resolvers and FuncHandlers may return it too. As in SQL,
+// operators given null yield null, except that false && null is false and
+// true || null is true.
+//
+var Null = NullValue{}
+
+// String returns "null".
+//
+func (NullValue) String() string {
+	return "null"
+}
+
+// nullBinary applies the binary operator op to left and right, at least one
+// of which is null, using three-valued logic for && and ||.
+func (e *evaluator) nullBinary(op *token, left, right interface{}) interface{} {
+	switch op.typ {
+	case tokenLogicalAnd, tokenLogicalOr:
+		_, lok := left.(bool)
+		_, rok := right.(bool)
+		if !(lok || left == Null) || !(rok || right == Null) {
+			e.error("Binary operation type error; left: %v (%T), right: %v (%T), op: %v",
+				left, left, right, right, op)
+		}
+		if op.typ == tokenLogicalAnd && (left == false || right == false) {
+			return false
+		}
+		if op.typ == tokenLogicalOr && (left == true || right == true) {
+			return true
+		}
+	}
+	return Null
+}
@@ -0,0 +1,69 @@
+package gocalc
+
+import "testing"
+
+type shipment struct {
+	Weight  *float64
+	Carrier interface{}
+}
+
+var nullTests = []expressionTest{
+	{true, "null", Null},
+	{true, "null + 1", Null},
+	{true, "-null", Null},
+	{true, "!null", Null},
+	{true, "1 < null", Null},
+	{true, "null = null", Null},
+	{true, "missing * 2", Null},
+	{true, "missing.total", Null},
+	{true, "items[missing]", Null},
+	{true, "false && null", false},
+	{true, "null && false", false},
+	{true, "true && null", Null},
+	{true, "true || null", true},
+	{true, "null || true", true},
+	{true, "false || null", Null},
+	{true, "null is null", true},
+	{true, "null is not null", false},
+	{true, "1 + missing is null", true},
+	{true, "unknown is null", true},
+	{true, "1 is not null && 2 is not null", true},
+	{true, "coalesce(missing, unknown, 3, 4)", 3},
+	{true, "coalesce(missing)", Null},
+	{true, "ifnull(missing, 5)", 5},
+	{true, "ifnull(2, 1 / 0)", 2},
+	{true, "abs(missing)", Null},
+	{true, "round(1.5, missing)", Null},
+	{true, "ship.Weight is null", true},
+	{true, "ship.Carrier", Null},
+	{false, "null && 1", nil},
+	{false, "coalesce()", nil},
+	{false, "ifnull(1)", nil},
+}
+
+func TestNull(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"unknown": Null,
+		"items":   []int{1, 2},
+		"ship":    shipment{},
+	})
+
+	for _, test := range nullTests {
+		checkEvaluation(t, test, params, nil, WithMissingAsNull())
+	}
+}
+
+func TestMissingIsUndefinedByDefault(t *testing.T) {
+	e, err := NewExpr("missing is null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Evaluate(nil, nil); err == nil {
+		t.Errorf("Evaluation of an undefined identifier passed without WithMissingAsNull")
+	}
+
+	res, err := e.Evaluate(MapEnv(map[string]interface{}{"missing": Null}), nil)
+	if err != nil || res != true {
+		t.Errorf("Evaluation returned %v, %v, expected true", res, err)
+	}
+}
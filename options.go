@@ -0,0 +1,166 @@
+package gocalc
+
+import (
+	"fmt"
+	"time"
+)
+
+// An Option configures how an Expression is compiled and evaluated.
+//
+type Option func(*config)
+
+type config struct {
+	decimal  bool             // float literals and results are Decimals
+	places   int              // digits kept after the decimal point by division
+	rounding RoundingMode     // rounding applied when digits are discarded
+	big      bool             // integers are *big.Int, and dividing them *big.Rat
+	demote   bool             // big results are demoted to int64 when they fit
+	nulls    bool             // undefined identifiers and nil fields are null
+	program  bool             // the source is a program of statements
+	syntax   Syntax           // which operators the source may use
+	math     bool             // a number multiplies the operands written after it
+	dialect  *Dialect         // operators added to the built-in ones
+	clock    func() time.Time // the time returned by now()
+	units    *Units           // the units quantities may be written in
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// check returns a diagnostic for each option given an invalid value.
+func (c *config) check() Diagnostics {
+	var d Diagnostics
+	if c.decimal && (c.places < 0 || c.places > maxPlaces) {
+		d = append(d, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("WithDecimal places %d out of range [0, %d]", c.places, maxPlaces),
+			Code:     CodeBadOption,
+		})
+	}
+	return d
+}
+
+// WithDecimal makes an Expression compute with exact Decimals instead of
+// float64. Float literals become Decimals, as do floats supplied by
+// resolvers and FuncHandlers, and dividing two integers yields a Decimal.
+// Inexact results are rounded to places digits after the decimal point
+// using rounding, which is also the rounding used by round(). places must
+// be from 0 to 1000, or compiling the Expression fails.
+//
+func WithDecimal(places int, rounding RoundingMode) Option {
+	return func(c *config) {
+		c.decimal = true
+		c.places = places
+		c.rounding = rounding
+	}
+}
+
+// WithBigNumbers makes an Expression compute exactly with arbitrarily large
+// integers and fractions. Integer literals and integers supplied by
+// resolvers and FuncHandlers become *big.Int, and dividing two integers
+// yields a *big.Rat. If demote is true, a result that is an integer that
+// fits in an int64 is returned as an int64.
+//
+func WithBigNumbers(demote bool) Option {
+	return func(c *config) {
+		c.big = true
+		c.demote = demote
+	}
+}
+
+// WithMissingAsNull makes identifiers that resolvers do not define, and nil
+// fields of structured parameters, evaluate to Null instead of failing.
+//
+func WithMissingAsNull() Option {
+	return func(c *config) {
+		c.nulls = true
+	}
+}
+
+// WithProgram compiles the source as a program: statements separated by
+// semicolons or newlines, each either an expression or an assignment like
+// name := expr. Assigned names shadow parameters in the statements that
+// follow. A newline within brackets, or before the end of an expression,
+// as in "1 +" and "2", does not end a statement. The result is the value of
+// the last statement, and EvaluateBindings returns the final value of every
+// assigned name as well.
+//
+func WithProgram() Option {
+	return func(c *config) {
+		c.program = true
+	}
+}
+
+// WithMathNotation lets a number be followed by identifiers and
+// parenthesized groups that it multiplies, as in "2x + 3(y - 1)" and
+// "2pi r". The product binds tighter than any operator, so "1 / 2x" is
+// 1 / (2 * x) and "-2x" is -(2 * x), while the field accesses and index
+// lookups of each factor bind tighter still, so "2x.y" is 2 * (x.y). An
+// identifier followed by a parenthesized group stays a function call: "2f(x)"
+// is 2 * f(x), not 2 * f * x. Only a number starts such a product, so "x y"
+// and "(x)(y)" are still errors, and suffixes that are part of a literal,
+// like the "i" of "2i", the "u" of "2u" and the units of durations like
+// "2h", are not identifiers. Write "2 h" to multiply by h. Since "2x^2"
+// would read as a power, "^", which is exclusive or, is an error in math
+// notation.
+//
+func WithMathNotation() Option {
+	return func(c *config) {
+		c.math = true
+	}
+}
+
+// WithDialect lets an Expression use the operators of d as well as the
+// built-in ones.
+//
+func WithDialect(d *Dialect) Option {
+	return func(c *config) {
+		c.dialect = d
+	}
+}
+
+// WithClock makes now() return the time given by clock instead of the
+// current time, so that expressions using it can be tested. The clock is
+// read at most once per evaluation.
+//
+func WithClock(clock func() time.Time) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithUnits lets quantity literals, like 5[km], and to() use the units of u,
+// which include the SI and common units that every Units has.
+//
+func WithUnits(u *Units) Option {
+	return func(c *config) {
+		c.units = u
+	}
+}
+
+// Syntax selects the dialect an Expression is written in.
+//
+type Syntax int
+
+const (
+	// SyntaxLegacy accepts both "=" and "==" as equality. It is the
+	// default.
+	SyntaxLegacy Syntax = iota
+
+	// SyntaxStrict accepts only "==" as equality, and reports a lone "=",
+	// which is easily mistaken for assignment, as an error.
+	SyntaxStrict
+)
+
+// WithSyntax sets the syntax an Expression is compiled with.
+//
+func WithSyntax(s Syntax) Option {
+	return func(c *config) {
+		c.syntax = s
+	}
+}
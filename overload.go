@@ -0,0 +1,146 @@
+package gocalc
+
+import "errors"
+
+// ErrOperandType is returned by the methods of the operator interfaces,
+// Adder, Multiplier, Comparer and Negater, when they do not support the type
+// of their operand.
+//
+// These interfaces let caller-defined values supplied by resolvers,
+// FuncHandlers and fields of structured parameters be operands of the
+// built-in operators. Such values are kept as they are by the evaluator,
+// even if their underlying type is a number. For x op y, the method of x
+// that implements op is called with y. If x has no such method, or the
+// method returns ErrOperandType, then the method of y is called with x for
+// + and *, which are taken to commute, and for comparisons, whose result is
+// reversed. Otherwise x op y fails as it would for any other unsupported
+// operands. So for a Money m, Mul and Quo should accept the numbers Money
+// may be scaled by, making m * 2, 2 * m and m / 2 work, while 2 / m still
+// fails.
+//
+// The operands the methods are called with are never Null, since any
+// operation on Null is Null, and are values as the evaluator holds them:
+// int64, float64, Decimal, *big.Int, *big.Rat, complex128, string, bool,
+// lists, maps, or caller-defined values. Results are converted like those
+// of a FuncHandler, and errors other than ErrOperandType fail the
+// evaluation.
+//
+var ErrOperandType = errors.New("unsupported operand type")
+
+// An Adder is a value that supports + and -. Add returns the receiver plus
+// y, and Sub the receiver minus y.
+//
+type Adder interface {
+	Add(y interface{}) (interface{}, error)
+	Sub(y interface{}) (interface{}, error)
+}
+
+// A Multiplier is a value that supports * and /. Mul returns the receiver
+// times y, and Quo the receiver divided by y.
+//
+type Multiplier interface {
+	Mul(y interface{}) (interface{}, error)
+	Quo(y interface{}) (interface{}, error)
+}
+
+// A Comparer is a value that supports <, <=, >, >=, == and !=, and so can
+// be sorted and be the argument of min and max. Compare returns -1, 0 or +1
+// as the receiver is less than, equal to or greater than y. Operands that
+// Compare returns an error for are unequal rather than failing == and !=.
+//
+type Comparer interface {
+	Compare(y interface{}) (int, error)
+}
+
+// A Negater is a value that supports unary -. Neg returns the negated
+// receiver.
+//
+type Negater interface {
+	Neg() (interface{}, error)
+}
+
+// overload applies op to left and right using the operator methods of
+// either, or returns nil if neither implements them for the other.
+func (e *evaluator) overload(op *token, left, right interface{}) interface{} {
+	if relationalOp(op) {
+		c, err := compare(left, right)
+		if errors.Is(err, ErrOperandType) {
+			return nil
+		}
+		e.check(err)
+		return compareBinary(op, c)
+	}
+	if m := method(op, left); m != nil {
+		if r, ok := e.overloaded(m(right)); ok {
+			return r
+		}
+	}
+	if op.typ == tokenPlus || op.typ == tokenStar {
+		if m := method(op, right); m != nil {
+			if r, ok := e.overloaded(m(left)); ok {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// method returns the method of x that applies the arithmetic operator op,
+// or nil.
+func method(op *token, x interface{}) func(interface{}) (interface{}, error) {
+	switch op.typ {
+	case tokenPlus, tokenMinus:
+		if a, ok := x.(Adder); ok {
+			if op.typ == tokenPlus {
+				return a.Add
+			}
+			return a.Sub
+		}
+	case tokenStar, tokenSlash:
+		if m, ok := x.(Multiplier); ok {
+			if op.typ == tokenStar {
+				return m.Mul
+			}
+			return m.Quo
+		}
+	}
+	return nil
+}
+
+// compare compares left and right using the Compare method of either, or
+// returns ErrOperandType if neither supports the other.
+func compare(left, right interface{}) (int, error) {
+	if c, ok := left.(Comparer); ok {
+		r, err := c.Compare(right)
+		if !errors.Is(err, ErrOperandType) {
+			return r, err
+		}
+	}
+	if c, ok := right.(Comparer); ok {
+		r, err := c.Compare(left)
+		if !errors.Is(err, ErrOperandType) {
+			return -r, err
+		}
+	}
+	return 0, ErrOperandType
+}
+
+// overloaded returns the result r of an operator method that returned err,
+// reporting false if the method does not support its operand.
+func (e *evaluator) overloaded(r interface{}, err error) (interface{}, bool) {
+	if errors.Is(err, ErrOperandType) {
+		return nil, false
+	}
+	e.check(err)
+	if r = e.normalize(r); r == nil {
+		e.error("Operator method returned no value")
+	}
+	return r, true
+}
+
+// check fails the evaluation with err, if it is not nil.
+func (e *evaluator) check(err error) {
+	if err != nil {
+		e.error("%s", err)
+	}
+}
@@ -0,0 +1,188 @@
+package gocalc
+
+import (
+	"fmt"
+	"testing"
+)
+
+// amount is an amount of money in cents of a currency.
+type amount struct {
+	cents    int64
+	currency string
+}
+
+func (a amount) other(y interface{}) (amount, error) {
+	b, ok := y.(amount)
+	if !ok {
+		return amount{}, ErrOperandType
+	}
+	if a.currency != b.currency {
+		return amount{}, fmt.Errorf("cannot combine %s and %s", a.currency, b.currency)
+	}
+	return b, nil
+}
+
+func (a amount) Add(y interface{}) (interface{}, error) {
+	b, err := a.other(y)
+	return amount{a.cents + b.cents, a.currency}, err
+}
+
+func (a amount) Sub(y interface{}) (interface{}, error) {
+	b, err := a.other(y)
+	return amount{a.cents - b.cents, a.currency}, err
+}
+
+func (a amount) Mul(y interface{}) (interface{}, error) {
+	if n, ok := y.(int64); ok {
+		return amount{a.cents * n, a.currency}, nil
+	}
+	return nil, ErrOperandType
+}
+
+func (a amount) Quo(y interface{}) (interface{}, error) {
+	switch n := y.(type) {
+	case int64:
+		if n == 0 {
+			return nil, fmt.Errorf("division of %v by zero", a)
+		}
+		return amount{a.cents / n, a.currency}, nil
+	case amount:
+		// The ratio of two amounts is a plain number.
+		if _, err := a.other(n); err != nil {
+			return nil, err
+		}
+		return float64(a.cents) / float64(n.cents), nil
+	}
+	return nil, ErrOperandType
+}
+
+func (a amount) Compare(y interface{}) (int, error) {
+	b, err := a.other(y)
+	switch {
+	case err != nil:
+		return 0, err
+	case a.cents < b.cents:
+		return -1, nil
+	case a.cents > b.cents:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (a amount) Neg() (interface{}, error) {
+	return amount{-a.cents, a.currency}, nil
+}
+
+func (a amount) String() string {
+	return fmt.Sprintf("%d.%02d %s", a.cents/100, a.cents%100, a.currency)
+}
+
+// vector is an array, which would be normalized to a list if it did not
+// implement the operator interfaces.
+type vector [3]float64
+
+func (v vector) Add(y interface{}) (interface{}, error) {
+	w, ok := y.(vector)
+	if !ok {
+		return nil, ErrOperandType
+	}
+	return vector{v[0] + w[0], v[1] + w[1], v[2] + w[2]}, nil
+}
+
+func (v vector) Sub(y interface{}) (interface{}, error) {
+	w, ok := y.(vector)
+	if !ok {
+		return nil, ErrOperandType
+	}
+	return vector{v[0] - w[0], v[1] - w[1], v[2] - w[2]}, nil
+}
+
+func (v vector) Mul(y interface{}) (interface{}, error) {
+	switch k := y.(type) {
+	case float64:
+		return vector{v[0] * k, v[1] * k, v[2] * k}, nil
+	case int64:
+		return v.Mul(float64(k))
+	case vector:
+		// The dot product.
+		return v[0]*k[0] + v[1]*k[1] + v[2]*k[2], nil
+	}
+	return nil, ErrOperandType
+}
+
+func (v vector) Quo(y interface{}) (interface{}, error) {
+	return nil, ErrOperandType
+}
+
+// seconds is an integer type, which would be normalized to an int64 if it
+// did not implement the operator interfaces.
+type seconds int64
+
+func (s seconds) Add(y interface{}) (interface{}, error) {
+	if t, ok := y.(seconds); ok {
+		return s + t, nil
+	}
+	return nil, ErrOperandType
+}
+
+func (s seconds) Sub(y interface{}) (interface{}, error) {
+	if t, ok := y.(seconds); ok {
+		return s - t, nil
+	}
+	return nil, ErrOperandType
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"price":  amount{1250, "EUR"},
+		"fee":    amount{199, "EUR"},
+		"usd":    amount{500, "USD"},
+		"prices": []interface{}{amount{300, "EUR"}, amount{100, "EUR"}, amount{200, "EUR"}},
+		"v":      vector{1, 2, 3},
+		"w":      vector{4, 5, 6},
+		"s":      seconds(90),
+		"none":   (*amount)(nil),
+	})
+
+	for _, test := range []expressionTest{
+		{true, "price + fee", amount{1449, "EUR"}},
+		{true, "price - fee - fee", amount{852, "EUR"}},
+		{true, "price * 3", amount{3750, "EUR"}},
+		{true, "3 * price", amount{3750, "EUR"}},
+		{true, "price / 2", amount{625, "EUR"}},
+		{true, "price / fee * 199", 1250.0},
+		{true, "-price", amount{-1250, "EUR"}},
+		{true, "price > fee", true},
+		{true, "price <= fee", false},
+		{true, "fee < price < fee * 10", true},
+		{true, "price == price + fee - fee", true},
+		{true, "price != fee", true},
+		{true, "price == usd", false},
+		{true, "price == 1250", false},
+		{true, "fee in [usd, fee]", true},
+		{true, "max(prices)", amount{300, "EUR"}},
+		{true, "min(prices)", amount{100, "EUR"}},
+		{true, "sortBy(prices, p => -p)[0]", amount{300, "EUR"}},
+		{true, "price + null", Null},
+		{false, "none", nil},
+		{true, "v + w", vector{5, 7, 9}},
+		{true, "2 * v - w", vector{-2, -1, 0}},
+		{true, "v * w", 32.0},
+		{true, "s + s", seconds(180)},
+		{false, "price + usd", nil},
+		{false, "price < usd", nil},
+		{false, "price + 1", nil},
+		{false, "1 + price", nil},
+		{false, "price * 1.5", nil},
+		{false, "2 / price", nil},
+		{false, "price / 0", nil},
+		{false, "1 - price", nil},
+		{false, "price > 1", nil},
+		{false, "-v", nil},
+		{false, "v / 2", nil},
+		{false, "s * 2", nil},
+		{false, "s + 1", nil},
+	} {
+		checkEvaluation(t, test, params, nil)
+	}
+}
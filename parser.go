@@ -2,151 +2,865 @@ package gocalc
 
 import (
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-func newParser(l lexer) *parser {
+func newParser(l lexer, opts ...Option) *parser {
 	return &parser{
-		lexer: l,
+		lexer:  l,
+		config: newConfig(opts),
+	}
+}
+
+// newExprParser returns a parser of expr, lexed with the operators of the
+// Dialect that opts may set.
+func newExprParser(expr string, opts []Option) *parser {
+	c := newConfig(opts)
+	return &parser{
+		lexer:  newLexer(expr, c.dialect),
+		config: c,
 	}
 }
 
 type parser struct {
-	lexer lexer
-	error string
+	lexer       lexer
+	config      *config
+	diagnostics Diagnostics
+	noIn        bool // whether in ends the expression, as in let bindings
+	newlines    bool // whether a newline ends the expression, as in programs
+	comparison  expr // the last comparison parsed, unless it was parenthesized
+}
+
+// ends reports whether the expression being parsed ends before t instead of
+// continuing with it.
+func (p *parser) ends(t *token) bool {
+	return p.noIn && t.typ == tokenIn || p.newlines && t.newline
+}
+
+// enclose makes every token continue the expression, as within brackets,
+// and returns a function restoring the previous settings.
+func (p *parser) enclose() func() {
+	noIn, newlines := p.noIn, p.newlines
+	p.noIn, p.newlines = false, false
+	return func() { p.noIn, p.newlines = noIn, newlines }
+}
+
+// errorf records a diagnostic spanning t. A diagnostic starting where the
+// previous one did is dropped, since it is almost always a cascade of the
+// first.
+func (p *parser) errorf(t *token, code string, format string, args ...interface{}) {
+	if l := len(p.diagnostics); l > 0 && p.diagnostics[l-1].Span.Start == t.pos {
+		return
+	}
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Span:     Span{t.pos, t.end},
+		Message:  fmt.Sprintf(format, args...),
+		Code:     code,
+	})
 }
 
+func (p *parser) bad(t *token) expr {
+	return &badExpr{t.pos, t.end}
+}
+
+// parseExpr parses the whole input. The returned tree is nil if any error
+// diagnostics were recorded.
 func (p *parser) parseExpr() expr {
+	if p.config.program {
+		return p.finish(p.parseProgram())
+	}
+
 	e := p.parse(0)
-	if e == nil {
-		return nil
-	} else if next := p.lexer.token(); next == nil || next.typ != tokenEOF {
-		p.error = fmt.Sprintf("parseExpr(): Expected EOF, got %s", next)
+	for {
+		next := p.lexer.peekToken()
+		if next.typ == tokenEOF {
+			break
+		}
+
+		// Skip the stray token and keep going, so that errors in the rest of
+		// the input are reported too.
+		p.errorf(next, CodeExpectedEOF, "Expected EOF, got \"%s\"", next)
+		p.consume()
+		if next = p.lexer.peekToken(); infixOp(next) {
+			e = p.parseBinary(e, 0)
+		} else if next.typ != tokenEOF && !syncPoint(next) {
+			p.parse(0)
+		}
+	}
+	return p.finish(e)
+}
+
+// finish returns e, or nil if any error diagnostics were recorded, which it
+// sorts by position. The options are checked here rather than before
+// parsing, so that their diagnostics, which span no input, cannot hide a
+// parse error at its start.
+func (p *parser) finish(e expr) expr {
+	p.diagnostics = append(p.diagnostics, p.config.check()...)
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Span.Start < p.diagnostics[j].Span.Start
+	})
+	if p.diagnostics.hasErrors() {
 		return nil
 	}
 	return e
 }
 
 func (p *parser) parsePrimary() expr {
-	token := p.lexer.token()
+	e := p.parsePostfix(p.parseOperand())
+	if p.config.math && isNumber(e) {
+		e = p.parseImplicit(e)
+	}
+	return e
+}
+
+// parseImplicit parses the identifiers and parenthesized groups that follow
+// the number x in math notation, each of which multiplies the product so far.
+// Being parsed as one primary, the product binds tighter than any operator.
+func (p *parser) parseImplicit(x expr) expr {
+	for {
+		next := p.lexer.peekToken()
+		if p.ends(next) || next.typ != tokenIdentifier && next.typ != tokenLeftParen {
+			return x
+		}
+		// number (IDENTIFIER | IDENTIFIER '(' args ')' | '(' expr ')') postfix*
+		x = &binaryExpr{
+			left:  x,
+			right: p.parsePostfix(p.parseOperand()),
+			op:    &token{typ: tokenStar, val: "*", pos: next.pos, end: next.pos},
+		}
+	}
+}
+
+// isNumber reports whether x is a number literal.
+func isNumber(x expr) bool {
+	switch x.(type) {
+	case *intExpr, *uintExpr, *bigIntExpr, *floatExpr, *decimalExpr, *complexExpr:
+		return true
+	}
+	return false
+}
+
+// parsePostfix parses any field accesses and index lookups that follow e.
+func (p *parser) parsePostfix(e expr) expr {
+	for {
+		token := p.lexer.peekToken()
+		if p.ends(token) {
+			return e
+		}
+		switch token.typ {
+		case tokenDot:
+			// e '.' IDENTIFIER
+			p.consume()
+			sel := p.lexer.peekToken()
+			if sel.typ != tokenIdentifier {
+				p.errorf(sel, CodeUnexpectedToken, "Expected field name after \".\", got \"%s\"", sel)
+				return p.bad(token)
+			}
+			p.consume()
+			e = &selectorExpr{
+				x:   e,
+				sel: sel.val,
+				pos: sel.pos,
+			}
+		case tokenLeftBracket:
+			// e '[' expr ']'
+			p.consume()
+			restore := p.enclose()
+			index := p.parse(0)
+			restore()
+			if next := p.lexer.peekToken(); next.typ == tokenEOF {
+				p.errorf(token, CodeUnclosedBracket, "Unclosed bracket")
+			} else if next.typ != tokenRightBracket {
+				p.errorf(next, CodeUnexpectedToken, "Expected right bracket, got \"%s\"", next)
+				p.skipTo(tokenRightBracket)
+			}
+			if p.lexer.peekToken().typ == tokenRightBracket {
+				p.consume()
+			}
+			e = &indexExpr{
+				x:      e,
+				index:  index,
+				lbrack: token.pos,
+			}
+		default:
+			return e
+		}
+	}
+}
+
+func (p *parser) parseOperand() expr {
+	token := p.lexer.peekToken()
+	if syncPoint(token) && !unaryOp(token) {
+		// Leave the token for the caller to resynchronize on, but skip a
+		// binary operator that is missing its left operand.
+		p.errorf(token, CodeUnexpectedToken, "Expected primary, got \"%s\"", token)
+		if binaryOp(token) {
+			p.consume()
+			if next := p.lexer.peekToken(); !syncPoint(next) || unaryOp(next) {
+				return p.parseOperand()
+			}
+		}
+		return p.bad(token)
+	}
+	p.consume()
+
+	if unit := p.lexer.peekToken(); (token.typ == tokenInt || token.typ == tokenFloat) &&
+		unit.typ == tokenIdentifier && unit.pos == token.end && durationUnits.MatchString(unit.val) {
+		// NUMBER UNIT, with no space between them
+		p.consume()
+		d, err := parseDuration(token.val + unit.val)
+		if err != nil {
+			p.errorf(token, CodeBadLiteral, "Invalid duration literal: \"%s%s\": %s", token.val, unit.val, err)
+			return p.bad(token)
+		}
+		return &durationExpr{d}
+	}
 
 	switch token.typ {
-	case tokenMinus, tokenPlus, tokenLogicalNot, tokenBitwiseNot:
+	case tokenMinus, tokenPlus, tokenLogicalNot, tokenBitwiseNot, tokenNot, tokenOperator:
 		e := p.parse(precedence(token, unary))
-		if e == nil {
-			return nil
-		}
 		return &unaryExpr{
 			expr: e,
 			op:   token,
 		}
 	case tokenLeftParen:
+		if p.lexer.peekToken().typ == tokenRightParen {
+			// '(' ')' '=>' expr
+			p.consume()
+			return p.parseLambda(token, nil)
+		}
+		restore := p.enclose()
 		e := p.parse(0)
-		token = p.lexer.token()
-		if token.typ != tokenRightParen {
-			p.error = fmt.Sprintln("Unclosed parenth")
-			return nil
+		restore()
+		p.comparison = nil
+		param, isParam := e.(*paramExpr)
+		if isParam && p.lexer.peekToken().typ == tokenComma {
+			// '(' IDENTIFIER (',' IDENTIFIER)* ')' '=>' expr
+			return p.parseLambda(token, p.parseParams(param))
+		}
+		if next := p.lexer.peekToken(); next.typ == tokenEOF {
+			p.errorf(token, CodeUnclosedParen, "Unclosed parenth")
+		} else if next.typ != tokenRightParen {
+			p.errorf(next, CodeUnexpectedToken, "Expected right paren, got \"%s\"", next)
+			p.skipTo(tokenRightParen)
+		}
+		if p.lexer.peekToken().typ == tokenRightParen {
+			p.consume()
+		}
+		if isParam && p.lexer.peekToken().typ == tokenArrow {
+			// '(' IDENTIFIER ')' '=>' expr
+			return p.parseLambda(token, []string{param.identifier})
 		}
 		return e
-	case tokenInt:
-		i, _ := strconv.ParseInt(token.val, 0, 32)
-		return &intExpr{i}
-	case tokenFloat:
-		f, _ := strconv.ParseFloat(token.val, 64)
-		return &floatExpr{f}
+	case tokenInt, tokenFloat:
+		x := p.parseNumber(token)
+		if next := p.lexer.peekToken(); next.typ == tokenLeftBracket && next.pos == token.end {
+			// NUMBER '[' UNIT ']', with no space before the bracket
+			return p.parseQuantity(x, next)
+		}
+		return x
+	case tokenUint:
+		u, err := strconv.ParseUint(strings.TrimSuffix(token.val, "u"), 0, 64)
+		if err != nil {
+			p.errorf(token, CodeBadLiteral, "Unsigned literal out of range: \"%s\"", token.val)
+			return p.bad(token)
+		}
+		return &uintExpr{u}
+	case tokenImaginary:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(token.val, "i"), 64)
+		if err != nil {
+			p.errorf(token, CodeBadLiteral, "Imaginary literal out of range: \"%s\"", token.val)
+			return p.bad(token)
+		}
+		return &complexExpr{complex(0, f)}
+	case tokenString:
+		str, err := strconv.Unquote(token.val)
+		if err != nil {
+			p.errorf(token, CodeBadLiteral, "Invalid string literal: %s", token.val)
+			return p.bad(token)
+		}
+		return &stringExpr{str}
 	case tokenTrue:
 		return &boolExpr{true}
 	case tokenFalse:
 		return &boolExpr{false}
+	case tokenNull:
+		return &nullExpr{}
+	case tokenLet:
+		// 'let' IDENTIFIER '=' expr (',' IDENTIFIER '=' expr)* 'in' expr
+		return p.parseLet(token)
+	case tokenLeftBracket:
+		// '[' ']' | '[' expr (',' expr)* ']'
+		return &listExpr{
+			elems:  p.parseList(token, tokenRightBracket),
+			lbrack: token.pos,
+		}
+	case tokenLeftBrace:
+		// '{' '}' | '{' key ':' expr (',' key ':' expr)* '}'
+		return p.parseMap(token)
 	case tokenIdentifier:
 		// IDENTIFIER | IDENTIFIER '(' args ')'
 		return p.parseIdentifier(token)
 	case tokenError:
-		p.error = fmt.Sprintf("Lexical error at %d: \"%s\"", token.pos, token.val)
-		return nil
+		p.errorf(token, CodeLexical, "Lexical error: \"%s\"", token.val)
+		return p.bad(token)
 	default:
-		p.error = fmt.Sprintf("Expected primary at %d, got \"%s\"", token.pos, token)
-		return nil
+		p.errorf(token, CodeUnexpectedToken, "Expected primary, got \"%s\"", token)
+		return p.bad(token)
 	}
 }
 
-func (p *parser) parseFunctionArgs() []expr {
+// parseNumber parses the int or float literal token, which has been
+// consumed.
+func (p *parser) parseNumber(token *token) expr {
+	switch {
+	case token.typ == tokenInt && p.config.big:
+		i, ok := new(big.Int).SetString(token.val, 0)
+		if !ok {
+			p.errorf(token, CodeBadLiteral, "Invalid integer literal: \"%s\"", token.val)
+			return p.bad(token)
+		}
+		return &bigIntExpr{i}
+	case token.typ == tokenInt:
+		i, err := strconv.ParseInt(token.val, 0, 64)
+		if err != nil {
+			p.errorf(token, CodeBadLiteral, "Integer literal out of range: \"%s\"", token.val)
+			return p.bad(token)
+		}
+		return &intExpr{i}
+	case p.config.decimal:
+		d, err := ParseDecimal(token.val)
+		if err != nil {
+			p.errorf(token, CodeBadLiteral, "Invalid decimal literal: \"%s\"", token.val)
+			return p.bad(token)
+		}
+		return &decimalExpr{d}
+	}
+	f, err := strconv.ParseFloat(token.val, 64)
+	if err != nil {
+		p.errorf(token, CodeBadLiteral, "Float literal out of range: \"%s\"", token.val)
+		return p.bad(token)
+	}
+	return &floatExpr{f}
+}
+
+// parseList parses the comma separated expressions that follow open, up to
+// and including the close token, which is either a right paren ending the
+// arguments of a function call or a right bracket ending a list literal.
+func (p *parser) parseList(open *token, close tokenType) []expr {
+	defer p.enclose()()
+	what, closeName, code, unclosed := "function argument", "right paren", CodeUnclosedParen, "Unclosed function call"
+	if close == tokenRightBracket {
+		what, closeName, code, unclosed = "list element", "right bracket", CodeUnclosedBracket, "Unclosed list"
+	}
+
 	peek := p.lexer.peekToken()
-	funcArgs := []expr{}
-	if peek.typ == tokenRightParen {
-		// IDENTIFIER '(' ')'
-		p.lexer.token()
-		return funcArgs
+	list := []expr{}
+	if peek.typ == close {
+		p.consume()
+		return list
 	}
 
 	for {
-		arg := p.parse(0)
-		if arg == nil {
-			return nil
-		}
-		funcArgs = append(funcArgs, arg)
-		if peek = p.lexer.peekToken(); peek.typ == tokenComma {
-			p.lexer.token()
-		} else if peek.typ == tokenRightParen {
-			p.lexer.token()
-			break
+		list = append(list, p.parse(0))
+		peek = p.lexer.peekToken()
+		if peek.typ != tokenComma && peek.typ != close && peek.typ != tokenEOF {
+			p.errorf(peek, CodeUnexpectedToken, "Expected a comma or %s after %s, got \"%s\"", closeName, what, peek)
+			p.skipTo(tokenComma, close)
+			peek = p.lexer.peekToken()
+		}
+
+		switch peek.typ {
+		case tokenComma:
+			p.consume()
+		case close:
+			p.consume()
+			return list
+		default:
+			p.errorf(open, code, "%s", unclosed)
+			return list
+		}
+	}
+}
+
+// parseMap parses the entries of a map literal that follow the left brace
+// open, up to and including the right brace. A key is a string literal or an
+// identifier, which stands for its own name.
+func (p *parser) parseMap(open *token) expr {
+	defer p.enclose()()
+	m := &mapExpr{lbrace: open.pos}
+	if p.lexer.peekToken().typ == tokenRightBrace {
+		p.consume()
+		return m
+	}
+
+	seen := map[string]bool{}
+	for {
+		key, t, ok := p.parseKey()
+		if ok && seen[key] {
+			p.errorf(t, CodeDuplicateKey, "Duplicate key %q in map literal", key)
+		}
+		seen[key] = true
+
+		if next := p.lexer.peekToken(); ok && next.typ != tokenColon {
+			p.errorf(next, CodeUnexpectedToken, "Expected a colon after map key, got \"%s\"", next)
+			p.skipTo(tokenColon, tokenComma, tokenRightBrace)
+		}
+		var value expr
+		if p.lexer.peekToken().typ == tokenColon {
+			p.consume()
+			value = p.parse(0)
 		} else {
-			p.error = fmt.Sprintf("Expected a comma or right paren after function argument, got \"%s\"", peek)
-			return nil
+			value = p.bad(p.lexer.peekToken())
+		}
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, value)
+
+		peek := p.lexer.peekToken()
+		if peek.typ != tokenComma && peek.typ != tokenRightBrace && peek.typ != tokenEOF {
+			p.errorf(peek, CodeUnexpectedToken, "Expected a comma or right brace after map entry, got \"%s\"", peek)
+			p.skipTo(tokenComma, tokenRightBrace)
+			peek = p.lexer.peekToken()
+		}
+
+		switch peek.typ {
+		case tokenComma:
+			p.consume()
+		case tokenRightBrace:
+			p.consume()
+			return m
+		default:
+			p.errorf(open, CodeUnclosedBrace, "Unclosed map")
+			return m
 		}
 	}
-	return funcArgs
+}
+
+// parseKey parses the key of a map entry. ok is false, and a diagnostic
+// recorded, if the next token is not a valid key.
+func (p *parser) parseKey() (key string, t *token, ok bool) {
+	t = p.lexer.peekToken()
+	switch t.typ {
+	case tokenIdentifier:
+		p.consume()
+		return t.val, t, true
+	case tokenString:
+		p.consume()
+		s, err := strconv.Unquote(t.val)
+		if err != nil {
+			p.errorf(t, CodeBadLiteral, "Invalid string literal: %s", t.val)
+			return "", t, false
+		}
+		return s, t, true
+	}
+	p.errorf(t, CodeUnexpectedToken, "Expected a map key, got \"%s\"", t)
+	p.skipTo(tokenColon, tokenComma, tokenRightBrace)
+	return "", t, false
 }
 
 func (p *parser) parseIdentifier(token *token) expr {
 	peeked := p.lexer.peekToken()
+	if p.ends(peeked) {
+		return &paramExpr{token.val}
+	}
 	switch peeked.typ {
+	case tokenArrow:
+		// IDENTIFIER '=>' expr
+		return p.parseLambda(token, []string{token.val})
 	case tokenLeftParen:
-		p.lexer.token()
-		args := p.parseFunctionArgs()
-		if args == nil {
-			return nil
-		}
+		p.consume()
 		return &funcExpr{
 			function: token.val,
-			args:     args,
+			args:     p.parseList(peeked, tokenRightParen),
 		}
 	default:
 		return &paramExpr{token.val}
 	}
 }
 
-func (p *parser) parse(prec int) expr {
-	e := p.parsePrimary()
-	if e == nil {
-		return nil
+// parseParams parses the parameters of a lambda that follow the first, up
+// to and including the right paren.
+func (p *parser) parseParams(first *paramExpr) []string {
+	params := []string{first.identifier}
+	for p.lexer.peekToken().typ == tokenComma {
+		p.consume()
+		t := p.lexer.peekToken()
+		if t.typ != tokenIdentifier {
+			p.errorf(t, CodeUnexpectedToken, "Expected a lambda parameter, got \"%s\"", t)
+			p.skipTo(tokenRightParen)
+			break
+		}
+		p.consume()
+		params = append(params, t.val)
+	}
+
+	switch next := p.lexer.peekToken(); next.typ {
+	case tokenRightParen:
+		p.consume()
+	case tokenEOF:
+		p.errorf(next, CodeUnclosedParen, "Unclosed lambda parameters")
+	default:
+		p.errorf(next, CodeUnexpectedToken, "Expected a comma or right paren after lambda parameter, got \"%s\"", next)
+		p.skipTo(tokenRightParen)
+		if p.lexer.peekToken().typ == tokenRightParen {
+			p.consume()
+		}
+	}
+	return params
+}
+
+// parseLambda parses the arrow and body of a lambda with the given
+// parameters, which starts at the token start.
+func (p *parser) parseLambda(start *token, params []string) expr {
+	seen := map[string]bool{}
+	for _, param := range params {
+		if seen[param] {
+			p.errorf(start, CodeDuplicateParam, "Duplicate lambda parameter \"%s\"", param)
+		}
+		seen[param] = true
+	}
+
+	if next := p.lexer.peekToken(); next.typ != tokenArrow {
+		p.errorf(next, CodeUnexpectedToken, "Expected \"=>\" after lambda parameters, got \"%s\"", next)
+		return p.bad(start)
+	}
+	p.consume()
+	return &lambdaExpr{
+		params: params,
+		body:   p.parse(0),
+		pos:    start.pos,
+	}
+}
+
+// parseProgram parses statements separated by semicolons or newlines, of
+// which there must be at least one. Empty statements are skipped.
+func (p *parser) parseProgram() expr {
+	prog := &programExpr{}
+	p.newlines = true
+	for {
+		next := p.lexer.peekToken()
+		for next.typ == tokenSemicolon {
+			p.consume()
+			next = p.lexer.peekToken()
+		}
+		if next.typ == tokenEOF {
+			break
+		}
+
+		start := next
+		prog.stmts = append(prog.stmts, p.parseStatement())
+		if next = p.lexer.peekToken(); next == start || !endsStatement(next) {
+			// Skip the rest of the line, and at least one token, so that
+			// errors in the statements that follow are reported too.
+			p.errorf(next, CodeExpectedEnd, "Expected a semicolon or newline after statement, got \"%s\"", next)
+			for {
+				p.consume()
+				if endsStatement(p.lexer.peekToken()) {
+					break
+				}
+			}
+		}
+	}
+
+	if len(prog.stmts) == 0 {
+		eof := p.lexer.peekToken()
+		p.errorf(eof, CodeUnexpectedToken, "Expected a statement, got \"%s\"", eof)
+	}
+	return prog
+}
+
+// endsStatement reports whether a program statement ends before t.
+func endsStatement(t *token) bool {
+	return t.typ == tokenSemicolon || t.typ == tokenEOF || t.newline
+}
+
+// parseStatement parses an expression, or an assignment of one to a name.
+func (p *parser) parseStatement() expr {
+	e := p.parse(0)
+	assign := p.lexer.peekToken()
+	if assign.typ != tokenAssign {
+		return e
+	}
+	p.consume()
+	// IDENTIFIER ':=' expr
+	name, ok := e.(*paramExpr)
+	if !ok {
+		p.errorf(assign, CodeUnexpectedToken, "Expected a name before \"%s\"", assign)
+		p.parse(0)
+		return p.bad(assign)
+	}
+	return &assignExpr{
+		name:  name.identifier,
+		value: p.parse(0),
 	}
+}
+
+// parseLet parses the bindings and body of a let expression, where the let
+// token has been consumed.
+func (p *parser) parseLet(let *token) expr {
+	l := &letExpr{}
+	seen := map[string]bool{}
+	for {
+		name := p.lexer.peekToken()
+		if name.typ != tokenIdentifier {
+			p.errorf(name, CodeUnexpectedToken, "Expected a name to bind, got \"%s\"", name)
+			return p.bad(let)
+		}
+		p.consume()
+		if seen[name.val] {
+			p.errorf(name, CodeDuplicateParam, "Duplicate let binding \"%s\"", name.val)
+		}
+		seen[name.val] = true
+
+		if next := p.lexer.peekToken(); next.typ != tokenEqual || next.val != "=" {
+			p.errorf(next, CodeUnexpectedToken, "Expected \"=\" after \"%s\", got \"%s\"", name, next)
+			return p.bad(let)
+		}
+		p.consume()
+		noIn := p.noIn
+		p.noIn = true
+		value := p.parse(0)
+		p.noIn = noIn
+		l.names = append(l.names, name.val)
+		l.values = append(l.values, value)
+
+		next := p.lexer.peekToken()
+		if next.typ == tokenComma {
+			p.consume()
+			continue
+		}
+		if next.typ != tokenIn {
+			p.errorf(next, CodeUnexpectedToken, "Expected a comma or \"in\" after let binding, got \"%s\"", next)
+			return p.bad(let)
+		}
+		p.consume()
+		l.body = p.parse(0)
+		return l
+	}
+}
+
+func (p *parser) parse(prec int) expr {
+	return p.parseBinary(p.parsePrimary(), prec)
+}
+
+// parseBinary parses the operators and right operands that follow the left
+// operand e, for as long as they bind at least as tightly as prec.
+func (p *parser) parseBinary(e expr, prec int) expr {
 	lookahead := p.lexer.peekToken()
-	for binaryOp(lookahead) && precedence(lookahead, binary) >= prec {
+	for infixOp(lookahead) && precedence(lookahead, binary) >= prec && !p.ends(lookahead) {
 		op := lookahead
 		p.consume()
+		switch op.typ {
+		case tokenIs:
+			e = p.parseIsNull(e, op)
+			lookahead = p.lexer.peekToken()
+			continue
+		case tokenNot:
+			e = p.parseNot(e, op)
+			lookahead = p.lexer.peekToken()
+			continue
+		case tokenBetween:
+			e = p.parseBetween(e, op, false)
+			lookahead = p.lexer.peekToken()
+			continue
+		}
+		if op.typ == tokenEqual && op.val == "=" && p.config.syntax == SyntaxStrict {
+			// Report it, but parse on as equality.
+			hint := `use "==" to compare values`
+			if p.config.program {
+				hint += `, or ":=" to assign them`
+			}
+			p.errorf(op, CodeSingleEqual, "\"=\" is not an operator in strict syntax; %s", hint)
+		}
+		if op.typ == tokenBitwiseXor && p.config.math {
+			// 2x^2 reads as a power, so report it, but parse on as
+			// exclusive or.
+			p.errorf(op, CodeMathCaret, "\"^\" is exclusive or, not a power, and is not allowed in math notation")
+		}
+		if relationalOp(op) && e == p.comparison {
+			// a < b < c means a < b && b < c, as in mathematics.
+			e = p.chain(e, op)
+			lookahead = p.lexer.peekToken()
+			continue
+		}
+
 		q := 1 + precedence(lookahead, binary)
-		r := p.parse(q)
-		if r == nil {
-			return nil
+		if rightAssociative(op) {
+			q--
 		}
-		e = &binaryExpr{
+		b := &binaryExpr{
 			left:  e,
-			right: r,
+			right: p.parse(q),
 			op:    op,
 		}
+		p.checkUnits(op, b.left, b.right)
+		switch {
+		case relationalOp(op):
+			p.comparison = b
+		case op.typ == tokenEqual || op.typ == tokenNotEqual:
+			// a < b == c could mean either (a < b) == c or a < b && b == c.
+			if b.left == p.comparison || b.right == p.comparison {
+				p.errorf(op, CodeMixedComparison, "Comparing with \"%s\" the result of a comparison is ambiguous; add parentheses", op)
+			}
+		}
+		e = b
 
 		lookahead = p.lexer.peekToken()
 	}
 	return e
 }
 
+// chain adds op and the operand that follows it to the comparison e, which
+// becomes a chain of comparisons.
+func (p *parser) chain(e expr, op *token) expr {
+	c, ok := e.(*compareExpr)
+	if !ok {
+		b := e.(*binaryExpr)
+		c = &compareExpr{
+			operands: []expr{b.left, b.right},
+			ops:      []*token{b.op},
+		}
+	}
+	c.operands = append(c.operands, p.parse(1+precedence(op, binary)))
+	c.ops = append(c.ops, op)
+	p.checkUnits(op, c.operands[len(c.operands)-2], c.operands[len(c.operands)-1])
+	p.comparison = c
+	return c
+}
+
+// parseIsNull parses the rest of `x is null` or `x is not null`, where
+// is has been consumed.
+func (p *parser) parseIsNull(x expr, is *token) expr {
+	not := false
+	if next := p.lexer.peekToken(); next.typ == tokenNot {
+		not = true
+		p.consume()
+	}
+	if next := p.lexer.peekToken(); next.typ != tokenNull {
+		p.errorf(next, CodeUnexpectedToken, "Expected null after \"%s\", got \"%s\"", is, next)
+		return p.bad(is)
+	}
+	p.consume()
+	return &isNullExpr{x, not}
+}
+
+// parseNot parses the rest of `x not in y` or `x not between low and high`,
+// where not has been consumed.
+func (p *parser) parseNot(x expr, not *token) expr {
+	switch next := p.lexer.peekToken(); next.typ {
+	case tokenIn:
+		p.consume()
+		op := &token{typ: tokenNotIn, val: "not in", pos: not.pos, end: next.end}
+		return &binaryExpr{
+			left:  x,
+			op:    op,
+			right: p.parse(1 + precedence(op, binary)),
+		}
+	case tokenBetween:
+		p.consume()
+		return p.parseBetween(x, next, true)
+	default:
+		p.errorf(next, CodeUnexpectedToken, "Expected in or between after \"%s\", got \"%s\"", not, next)
+		return p.bad(not)
+	}
+}
+
+// parseBetween parses the bounds of `x between low and high`, where between
+// has been consumed. The bounds bind tighter than between, so that the and
+// separating them is not taken for a logical and.
+func (p *parser) parseBetween(x expr, between *token, not bool) expr {
+	q := 1 + precedence(between, binary)
+	low := p.parse(q)
+	if and := p.lexer.peekToken(); and.typ != tokenLogicalAnd {
+		p.errorf(and, CodeUnexpectedToken, "Expected and after the lower bound of %s, got \"%s\"", between, and)
+		return p.bad(between)
+	}
+	p.consume()
+	return &betweenExpr{
+		x:    x,
+		low:  low,
+		high: p.parse(q),
+		not:  not,
+	}
+}
+
 func (p *parser) consume() {
 	p.lexer.token()
 }
 
+// skipTo discards tokens until one of the given types is found at the
+// current nesting depth, or EOF is reached. The matching token is not
+// consumed.
+func (p *parser) skipTo(types ...tokenType) {
+	depth := 0
+	for {
+		t := p.lexer.peekToken()
+		if t.typ == tokenEOF {
+			return
+		}
+		if depth == 0 {
+			for _, typ := range types {
+				if t.typ == typ {
+					return
+				}
+			}
+		}
+		switch t.typ {
+		case tokenLeftParen, tokenLeftBracket, tokenLeftBrace:
+			depth++
+		case tokenRightParen, tokenRightBracket, tokenRightBrace:
+			depth--
+		}
+		p.consume()
+	}
+}
+
+// syncPoint reports whether the parser can resynchronize on token after an
+// error.
+func syncPoint(token *token) bool {
+	switch token.typ {
+	case tokenEOF, tokenComma, tokenColon, tokenSemicolon, tokenRightParen, tokenRightBracket, tokenRightBrace:
+		return true
+	}
+	return binaryOp(token)
+}
+
+func unaryOp(token *token) bool {
+	return precedence(token, unary) >= 0
+}
+
 func binaryOp(token *token) bool {
-	return token.typ > tokenBinary
+	return token.typ > tokenBinary || token.typ == tokenOperator && token.op.binary != nil
+}
+
+// rightAssociative reports whether the binary operator token groups from the
+// right.
+func rightAssociative(token *token) bool {
+	return token.typ == tokenOperator && token.op.binary.Associativity == RightAssociative
+}
+
+// relationalOp reports whether token is one of the ordering comparisons,
+// which can be chained.
+func relationalOp(token *token) bool {
+	switch token.typ {
+	case tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual:
+		return true
+	}
+	return false
+}
+
+// infixOp reports whether token continues an expression after an operand,
+// as binary operators, is, not in and between do.
+func infixOp(token *token) bool {
+	switch token.typ {
+	case tokenNot, tokenBetween:
+		return true
+	}
+	return binaryOp(token)
 }
 
 type operatorType int
@@ -158,11 +872,25 @@ const (
 )
 
 func precedence(token *token, operatorType operatorType) int {
+	if token.typ == tokenOperator {
+		switch {
+		case operatorType == unary && token.op.unary != nil:
+			return token.op.unary.Precedence
+		case operatorType == binary && token.op.binary != nil:
+			return token.op.binary.Precedence
+		}
+		return -1
+	}
+
 	switch operatorType {
 	case unary:
 		switch token.typ {
-		case tokenMinus, tokenLogicalNot, tokenBitwiseNot:
+		case tokenPlus, tokenMinus, tokenLogicalNot, tokenBitwiseNot:
 			return 10
+		case tokenNot:
+			// Looser than the comparisons but tighter than and, so that, as
+			// in SQL and Python, not x > 5 is not (x > 5).
+			return 2
 		}
 	case binary:
 		switch token.typ {
@@ -176,9 +904,10 @@ func precedence(token *token, operatorType operatorType) int {
 			return 3
 		case tokenBitwiseAnd:
 			return 4
-		case tokenEqual, tokenNotEqual:
+		case tokenEqual, tokenNotEqual, tokenIs:
 			return 5
-		case tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual:
+		case tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual, tokenIn, tokenNotIn,
+			tokenNot, tokenBetween:
 			return 6
 		case tokenLeftShift, tokenRightShift:
 			return 7
@@ -1,6 +1,9 @@
 package gocalc
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 var parserTests = []struct {
 	ok   bool
@@ -58,6 +61,21 @@ var parserTests = []struct {
 	// Parenthesized
 	{true, "(7)"},
 	{false, "(1 + 2"},
+
+	// Postfix
+	{true, "a.b"},
+	{true, "a.b.c"},
+	{true, "a[0]"},
+	{true, `a["k"].b[1][2]`},
+	{true, "f(x).y"},
+	{true, "(a).b"},
+	{true, "-a.b[0]"},
+	{false, "a."},
+	{false, "a.1"},
+	{false, "a[0"},
+	{false, "a[]"},
+	{false, "a[0)"},
+	{false, `"abc`},
 }
 
 func TestParse(t *testing.T) {
@@ -70,6 +88,233 @@ func TestParse(t *testing.T) {
 	}
 }
 
+var diagnosticTests = []struct {
+	expr  string
+	codes []string
+}{
+	{"1 + 2", nil},
+	{"1 +", []string{CodeUnexpectedToken}},
+	{"1 + * 2", []string{CodeUnexpectedToken}},
+	{"(1 + 2", []string{CodeUnclosedParen}},
+	{"f(1 2, 3", []string{CodeUnclosedParen, CodeUnexpectedToken}},
+	{"f(,) + (3 4) + @", []string{CodeUnexpectedToken, CodeUnexpectedToken, CodeUnexpectedToken, CodeLexical}},
+	{"1 + 1 1 * ) 2", []string{CodeExpectedEOF, CodeUnexpectedToken}},
+	{"1 +) * 2", []string{CodeUnexpectedToken}},
+	{"99999999999999999999 + 1.0 +", []string{CodeBadLiteral, CodeUnexpectedToken}},
+	{"a[1 + b[2", []string{CodeUnclosedBracket, CodeUnclosedBracket}},
+	{"a[1 2] + c.(", []string{CodeUnexpectedToken, CodeUnexpectedToken}},
+	{"a is not null", nil},
+	{"[1, 2] + [[]]", nil},
+	{"[1 2, 3", []string{CodeUnclosedBracket, CodeUnexpectedToken}},
+	{"x in [1, , 2]", []string{CodeUnexpectedToken}},
+	{"a is 1 + b is", []string{CodeUnexpectedToken, CodeUnexpectedToken}},
+	{`{a: 1, "b c": {}}`, nil},
+	{"{a: 1, a: 2}", []string{CodeDuplicateKey}},
+	{"{a 1, 2: 3}", []string{CodeUnexpectedToken, CodeUnexpectedToken}},
+	{"{a: 1 b: 2", []string{CodeUnclosedBrace, CodeUnexpectedToken}},
+	{"f(x => x, (a, b) => a + b, (y) => y, () => 1)", nil},
+	{"(a, a) => a", []string{CodeDuplicateParam}},
+	{"(a, 1) => a", []string{CodeUnexpectedToken}},
+	{"(a, b) + 1", []string{CodeUnexpectedToken}},
+	{"(a, b", []string{CodeUnclosedParen}},
+	{"(a b) => a", []string{CodeUnexpectedToken}},
+	{"let x = 1, y = x in x in [y]", nil},
+	{"let x = 1, x = 2 in x", []string{CodeDuplicateParam}},
+	{"let 1 = 2 in 3", []string{CodeUnexpectedToken}},
+	{"let x 1 in x", []string{CodeUnexpectedToken}},
+	{"let x = 1", []string{CodeUnexpectedToken}},
+	{"let = 1", []string{CodeUnexpectedToken}},
+	{"not a and b or c not in d and e not between f and g", nil},
+	{"a not 1", []string{CodeUnexpectedToken}},
+	{"a between 1 or 2", []string{CodeUnexpectedToken}},
+	{"a between 1 and", []string{CodeUnexpectedToken}},
+	{"0 <= x < 10 && a > b >= c", nil},
+	{"a < b == c", []string{CodeMixedComparison}},
+	{"a == b < c", []string{CodeMixedComparison}},
+	{"a < b != c < d", []string{CodeMixedComparison}},
+	{"(a < b) == c && a == (b < c)", nil},
+	{"a == b == c", nil},
+}
+
+func TestStrictSyntaxDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		program bool
+		codes   []string
+		hint    string
+	}{
+		{"a == b && c != d", false, nil, ""},
+		{"let x = 1 in x == 1", false, nil, ""},
+		{"a = b", false, []string{CodeSingleEqual}, `use "==" to compare values`},
+		{"a = b || (c = d)", false, []string{CodeSingleEqual, CodeSingleEqual}, ""},
+		{"x = 1; x", true, []string{CodeSingleEqual}, `or ":=" to assign them`},
+		{"let x == 1 in x", false, []string{CodeUnexpectedToken}, ""},
+	} {
+		opts := []Option{WithSyntax(SyntaxStrict)}
+		if test.program {
+			opts = append(opts, WithProgram())
+		}
+		diags := Check(test.expr, opts...)
+		if len(diags) != len(test.codes) {
+			t.Errorf("Check of \"%s\": expected %d diagnostics, got %d: %v", test.expr, len(test.codes), len(diags), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Code != test.codes[i] {
+				t.Errorf("Check of \"%s\": diagnostic %d: expected code %s, got %s (%v)", test.expr, i, test.codes[i], d.Code, d)
+			}
+		}
+		if len(diags) > 0 && !strings.Contains(diags[0].Message, test.hint) {
+			t.Errorf("Check of \"%s\": expected a hint %q, got %q", test.expr, test.hint, diags[0].Message)
+		}
+	}
+
+	if diags := Check("a = b"); len(diags) != 0 {
+		t.Errorf("Check of \"a = b\" in legacy syntax: %v", diags)
+	}
+}
+
+func TestMathNotation(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"x":  2,
+		"y":  3,
+		"r":  0.5,
+		"pi": 3.0,
+		"v":  map[string]interface{}{"w": 10},
+	})
+	h := func(fn string, args ...func() interface{}) (interface{}, bool) {
+		if fn == "f" {
+			return args[0](), true
+		}
+		return nil, false
+	}
+
+	for _, test := range []struct {
+		expr    string
+		printed string
+		expect  interface{}
+	}{
+		{"2x + 3(y - 1)", "2 * x + 3 * (y - 1)", int64(10)},
+		{"2pi r", "2 * pi * r", 3.0},
+		{"2 x", "2 * x", int64(4)},
+		{"2.5x", "2.5 * x", 5.0},
+		{"8 / 2x", "8 / (2 * x)", int64(2)},
+		{"12 / 3(y - 1)", "12 / (3 * (y - 1))", int64(2)},
+		{"-2x", "-(2 * x)", int64(-4)},
+		{"2x * y", "2 * x * y", int64(12)},
+		{"2x.w", "2 * x.w", nil},
+		{"2v.w", "2 * v.w", int64(20)},
+		{"2f(y)", "2 * f(y)", int64(6)},
+		{"2(x)(y)", "2 * x * y", int64(12)},
+		{"f(2x)", "f(2 * x)", int64(4)},
+		{"3 in [2y - 3]", "3 in [2 * y - 3]", true},
+		{"2i", "2i", complex(0, 2)},
+		{"2u", "2u", uint64(2)},
+	} {
+		e, err := NewExpr(test.expr, WithMathNotation())
+		if err != nil {
+			t.Errorf("Expression \"%s\": Cannot test; lexer or parser error: %v", test.expr, err)
+			continue
+		}
+		if s := e.String(); s != test.printed {
+			t.Errorf("Expression \"%s\": printed as %q, expected %q", test.expr, s, test.printed)
+		}
+		res, err := e.Evaluate(params, h)
+		if test.expect == nil {
+			if err == nil {
+				t.Errorf("Expression \"%s\": Evaluation passed but should have failed", test.expr)
+			}
+		} else if err != nil {
+			t.Errorf("Expression \"%s\": Evaluation failed with error: %v", test.expr, err)
+		} else if res != test.expect {
+			t.Errorf("Expression \"%s\": Evaluation returned %v (%T), expected %v (%T)", test.expr, res, res, test.expect, test.expect)
+		}
+	}
+
+	for _, src := range []string{"2x", "3(y - 1)", "x y", "(x)(y)"} {
+		if _, err := NewExpr(src); err == nil {
+			t.Errorf("Expression \"%s\" compiled without WithMathNotation", src)
+		}
+	}
+	for _, src := range []string{"x y", "(x)(y)", "x 2", "2 3"} {
+		if _, err := NewExpr(src, WithMathNotation()); err == nil {
+			t.Errorf("Expression \"%s\" compiled with WithMathNotation", src)
+		}
+	}
+	e, err := NewExpr("a := 2\nb := 3\na b", WithProgram(), WithMathNotation())
+	if err == nil {
+		t.Errorf("Program with \"a b\" compiled: %v", e)
+	}
+	for _, src := range []string{"2x^2", "x ^ 2", "2^3 + 1"} {
+		if diags := Check(src, WithMathNotation()); len(diags) != 1 || diags[0].Code != CodeMathCaret {
+			t.Errorf("Check of \"%s\" with WithMathNotation: %v, expected one %s diagnostic", src, diags, CodeMathCaret)
+		}
+	}
+	if diags := Check("x ^ 2"); len(diags) != 0 {
+		t.Errorf("Check of \"x ^ 2\" without WithMathNotation: %v", diags)
+	}
+
+	e, err = NewExpr("a := 2\n(a)", WithProgram(), WithMathNotation())
+	if err != nil {
+		t.Fatalf("Program ending in a newline before a group: %v", err)
+	}
+	if res, _, err := e.EvaluateBindings(nil, nil); err != nil || res != int64(2) {
+		t.Errorf("Program ending in a newline before a group: returned %v, %v; expected 2", res, err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	for _, test := range diagnosticTests {
+		diags := Check(test.expr)
+		if len(diags) != len(test.codes) {
+			t.Errorf("Check of \"%s\": expected %d diagnostics, got %d: %v", test.expr, len(test.codes), len(diags), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Code != test.codes[i] {
+				t.Errorf("Check of \"%s\": diagnostic %d: expected code %s, got %s (%v)", test.expr, i, test.codes[i], d.Code, d)
+			}
+			if i > 0 && d.Span.Start < diags[i-1].Span.Start {
+				t.Errorf("Check of \"%s\": diagnostics out of order: %v", test.expr, diags)
+			}
+		}
+
+		_, err := NewExpr(test.expr)
+		if len(test.codes) == 0 {
+			if err != nil {
+				t.Errorf("NewExpr(\"%s\"): unexpected error %v", test.expr, err)
+			}
+		} else if d, ok := err.(Diagnostics); !ok || len(d) != len(test.codes) {
+			t.Errorf("NewExpr(\"%s\"): expected %d diagnostics, got %#v", test.expr, len(test.codes), err)
+		}
+	}
+}
+
+// Malformed literals lex as several tokens, so it is the parser that reports
+// them.
+var malformedLiteralTests = []struct {
+	expr    string
+	message string
+}{
+	{"3a", `Expected EOF, got "a"`},
+	{"0b1g", `Expected EOF, got "g"`},
+	{"08", `Expected EOF, got "8"`},
+	{".", `Expected primary, got "."`},
+	{"5..", `Expected field name after ".", got "EOF"`},
+	{"3..5", `Expected field name after ".", got "5"`},
+}
+
+func TestMalformedLiteralDiagnostics(t *testing.T) {
+	for _, test := range malformedLiteralTests {
+		if diags := Check(test.expr); len(diags) != 1 || diags[0].Message != test.message {
+			t.Errorf("Check of \"%s\": expected one diagnostic %q, got %v", test.expr, test.message, diags)
+		}
+		if _, err := NewExpr(test.expr); err == nil {
+			t.Errorf("NewExpr(\"%s\"): compiled, expected an error", test.expr)
+		}
+	}
+}
+
 type mockLexer struct {
 	cur    int // index of current token
 	tokens []*token
@@ -93,7 +338,7 @@ func newMockLexer(expr string) *mockLexer {
 	l := &mockLexer{}
 
 	// Use gocalcLexer to fill mockLexer's token buffer
-	rl := newLexer(expr)
+	rl := newLexer(expr, nil)
 	for {
 		t := rl.token()
 		l.tokens = append(l.tokens, t)
@@ -131,14 +376,14 @@ func TestParserCountMallocs(t *testing.T) {
 }
 
 func shouldParse(s string, t *testing.T) {
-	p := newParser(newLexer(s))
+	p := newParser(newLexer(s, nil))
 	if e := p.parseExpr(); e == nil {
-		t.Fatalf("Parse of \"%s\" failed: %s", s, p.error)
+		t.Fatalf("Parse of \"%s\" failed: %s", s, p.diagnostics)
 	}
 }
 
 func shouldFail(s string, t *testing.T) {
-	p := newParser(newLexer(s))
+	p := newParser(newLexer(s, nil))
 	if e := p.parseExpr(); e != nil {
 		t.Fatalf("Parse of %s passed but should have failed.", s)
 	}
@@ -0,0 +1,390 @@
+package gocalc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A printer prints an expression tree back to source, adding only the
+// parentheses that are needed to parse it into the same tree.
+type printer struct {
+	buffer  buffer
+	noIn    bool     // whether in must be parenthesized, as in let bindings
+	dialect *Dialect // the operators the expression may use beyond the built-in ones
+}
+
+func newPrinter(d *Dialect) *printer {
+	return &printer{dialect: d}
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&p.buffer, format, args...)
+}
+
+// String returns the source of the expression as printed from its tree, with
+// canonical spacing and only the parentheses that are needed. Compiling the
+// result with the same options gives an equivalent Expression.
+//
+func (e *Expression) String() string {
+	p := newPrinter(e.config.dialect)
+	e.tree.accept(p)
+	return string(p.buffer)
+}
+
+// Operands that are not binary expressions bind tighter than any operator,
+// except for lambdas and lets, whose bodies extend as far as possible.
+const (
+	precOpen    = -1
+	precIsNull  = 5
+	precCompare = 6
+	precBetween = 6
+	precOperand = 11
+)
+
+// exprPrec returns the precedence of x as an operand.
+func exprPrec(x expr) int {
+	switch x := x.(type) {
+	case *binaryExpr:
+		return precedence(x.op, binary)
+	case *isNullExpr:
+		return precIsNull
+	case *betweenExpr:
+		return precBetween
+	case *compareExpr:
+		return precCompare
+	case *unaryExpr:
+		return precedence(x.op, unary)
+	case *lambdaExpr, *letExpr:
+		return precOpen
+	}
+	return precOperand
+}
+
+// before returns the precedence x needs to be printed without parentheses
+// before an operator of precedence prec, given that it needs min otherwise.
+// The operand of a unary operator takes in the operators that bind at least
+// as tightly as the unary operator, so ?x + 1 is ?(x + 1) if the dialect's ?
+// binds no tighter than +.
+func before(x expr, prec, min int) int {
+	if u, ok := x.(*unaryExpr); ok && precedence(u.op, unary) <= prec {
+		return precOperand
+	}
+	return min
+}
+
+// operand prints x, in parentheses if its precedence is less than prec.
+func (p *printer) operand(x expr, prec int) {
+	if exprPrec(x) < prec {
+		p.parens(x)
+		return
+	}
+	x.accept(p)
+}
+
+// parens prints x in parentheses.
+func (p *printer) parens(x expr) {
+	p.printf("(")
+	p.bracketed(x)
+	p.printf(")")
+}
+
+// bracketed prints x, which is enclosed in brackets of some kind, so that in
+// needs no parentheses.
+func (p *printer) bracketed(x expr) {
+	noIn := p.noIn
+	p.noIn = false
+	x.accept(p)
+	p.noIn = noIn
+}
+
+// list prints xs separated by commas.
+func (p *printer) list(xs []expr) {
+	noIn := p.noIn
+	p.noIn = false
+	defer func() { p.noIn = noIn }()
+	for i, x := range xs {
+		if i > 0 {
+			p.printf(", ")
+		}
+		x.accept(p)
+	}
+}
+
+func (p *printer) visitBinaryExpr(b *binaryExpr) {
+	if p.noIn && b.op.typ == tokenIn {
+		p.parens(b)
+		return
+	}
+	prec := precedence(b.op, binary)
+	left, right := prec, prec+1
+	// Operators of the same precedence group from the left, unless both are
+	// right associative.
+	if l, ok := b.left.(*binaryExpr); ok && rightAssociative(l.op) || !ok && rightAssociative(b.op) {
+		left = prec + 1
+	}
+	if rightAssociative(b.op) {
+		right = prec
+	}
+	p.comparand(b.op, b.left, before(b.left, prec, left))
+	p.printf(" %s ", b.op.val)
+	p.comparand(b.op, b.right, right)
+}
+
+// comparand prints x, the operand of op, like operand does, but also in
+// parentheses if both are comparisons, which would otherwise chain or be
+// reported as ambiguous.
+func (p *printer) comparand(op *token, x expr, prec int) {
+	switch op.typ {
+	case tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual, tokenEqual, tokenNotEqual:
+		if isComparison(x) {
+			p.parens(x)
+			return
+		}
+	}
+	p.operand(x, prec)
+}
+
+// isComparison reports whether x is a comparison or a chain of them.
+func isComparison(x expr) bool {
+	switch x := x.(type) {
+	case *compareExpr:
+		return true
+	case *binaryExpr:
+		return relationalOp(x.op)
+	}
+	return false
+}
+
+func (p *printer) visitCompareExpr(c *compareExpr) {
+	p.operand(c.operands[0], before(c.operands[0], precCompare, precCompare))
+	for i, op := range c.ops {
+		p.printf(" %s ", op.val)
+		p.operand(c.operands[i+1], precCompare+1)
+	}
+}
+
+func (p *printer) visitFuncExpr(f *funcExpr) {
+	p.printf("%s(", f.function)
+	p.list(f.args)
+	p.printf(")")
+}
+
+func (p *printer) visitParamExpr(e *paramExpr) {
+	p.printf("%s", e.identifier)
+}
+
+// base prints the operand of a selector or index expression.
+func (p *printer) base(x expr) {
+	switch x.(type) {
+	case *intExpr, *bigIntExpr, *floatExpr, *decimalExpr:
+		// 1.x would lex as a float, and 1[x] as a quantity.
+		p.parens(x)
+		return
+	}
+	p.operand(x, precOperand)
+}
+
+func (p *printer) visitSelectorExpr(e *selectorExpr) {
+	p.base(e.x)
+	p.printf(".%s", e.sel)
+}
+
+func (p *printer) visitIndexExpr(e *indexExpr) {
+	p.base(e.x)
+	p.printf("[")
+	p.bracketed(e.index)
+	p.printf("]")
+}
+
+func (p *printer) visitIsNullExpr(e *isNullExpr) {
+	p.operand(e.x, before(e.x, precIsNull, precIsNull))
+	if e.not {
+		p.printf(" is not null")
+	} else {
+		p.printf(" is null")
+	}
+}
+
+func (p *printer) visitBetweenExpr(b *betweenExpr) {
+	p.operand(b.x, before(b.x, precBetween, precBetween))
+	if b.not {
+		p.printf(" not")
+	}
+	p.printf(" between ")
+	p.operand(b.low, precBetween+1)
+	p.printf(" and ")
+	p.operand(b.high, precBetween+1)
+}
+
+func (p *printer) visitUnaryExpr(u *unaryExpr) {
+	p.printf("%s", u.op.val)
+	if u.op.typ == tokenNot || isIdentifier(u.op.val) || p.dialect != nil && startsUnary(u.expr) {
+		// A dialect's symbol may be written with the characters of two
+		// unary operators, so keep them apart.
+		p.printf(" ")
+	}
+	p.operand(u.expr, precedence(u.op, unary))
+}
+
+// startsUnary reports whether x may be printed starting with a unary
+// operator.
+func startsUnary(x expr) bool {
+	for {
+		switch y := x.(type) {
+		case *unaryExpr:
+			return true
+		case *binaryExpr:
+			x = y.left
+		case *compareExpr:
+			x = y.operands[0]
+		case *isNullExpr:
+			x = y.x
+		case *betweenExpr:
+			x = y.x
+		case *selectorExpr:
+			x = y.x
+		case *indexExpr:
+			x = y.x
+		default:
+			return false
+		}
+	}
+}
+
+func (p *printer) visitListExpr(l *listExpr) {
+	p.printf("[")
+	p.list(l.elems)
+	p.printf("]")
+}
+
+func (p *printer) visitMapExpr(m *mapExpr) {
+	p.printf("{")
+	for i, key := range m.keys {
+		if i > 0 {
+			p.printf(", ")
+		}
+		if isIdentifier(key) {
+			p.printf("%s: ", key)
+		} else {
+			p.printf("%s: ", strconv.Quote(key))
+		}
+		p.bracketed(m.values[i])
+	}
+	p.printf("}")
+}
+
+// isIdentifier reports whether s lexes as an identifier that is not a
+// keyword.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	if _, ok := keywords[s]; ok {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *printer) visitLambdaExpr(l *lambdaExpr) {
+	if len(l.params) == 1 {
+		p.printf("%s => ", l.params[0])
+	} else {
+		p.printf("(%s) => ", strings.Join(l.params, ", "))
+	}
+	l.body.accept(p)
+}
+
+func (p *printer) visitLetExpr(l *letExpr) {
+	p.printf("let ")
+	noIn := p.noIn
+	p.noIn = true
+	for i, name := range l.names {
+		if i > 0 {
+			p.printf(", ")
+		}
+		p.printf("%s = ", name)
+		l.values[i].accept(p)
+	}
+	p.noIn = noIn
+	p.printf(" in ")
+	l.body.accept(p)
+}
+
+func (p *printer) visitProgramExpr(prog *programExpr) {
+	for i, stmt := range prog.stmts {
+		if i > 0 {
+			p.printf("; ")
+		}
+		stmt.accept(p)
+	}
+}
+
+func (p *printer) visitAssignExpr(a *assignExpr) {
+	p.printf("%s := ", a.name)
+	a.value.accept(p)
+}
+
+func (p *printer) visitBoolExpr(b *boolExpr) {
+	p.printf("%t", b.val)
+}
+
+func (p *printer) visitNullExpr(n *nullExpr) {
+	p.printf("null")
+}
+
+func (p *printer) visitFloatExpr(f *floatExpr) {
+	s := strconv.FormatFloat(f.val, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	p.printf("%s", s)
+}
+
+func (p *printer) visitComplexExpr(c *complexExpr) {
+	p.printf("%si", strconv.FormatFloat(imag(c.val), 'f', -1, 64))
+}
+
+func (p *printer) visitDecimalExpr(d *decimalExpr) {
+	// A trailing point keeps the scale of a literal like 1. at zero.
+	s := d.val.String()
+	if !strings.Contains(s, ".") {
+		s += "."
+	}
+	p.printf("%s", s)
+}
+
+func (p *printer) visitIntExpr(i *intExpr) {
+	p.printf("%d", i.val)
+}
+
+func (p *printer) visitUintExpr(u *uintExpr) {
+	p.printf("%du", u.val)
+}
+
+func (p *printer) visitBigIntExpr(b *bigIntExpr) {
+	p.printf("%s", b.val)
+}
+
+func (p *printer) visitDurationExpr(d *durationExpr) {
+	p.printf("%s", formatDuration(d.val))
+}
+
+func (p *printer) visitQuantityExpr(q *quantityExpr) {
+	q.x.accept(p)
+	p.printf("[%s]", q.unit)
+}
+
+func (p *printer) visitStringExpr(e *stringExpr) {
+	p.printf("%s", strconv.Quote(e.val))
+}
+
+func (p *printer) visitBadExpr(b *badExpr) {
+	p.printf("BadExpr")
+}
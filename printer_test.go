@@ -0,0 +1,97 @@
+package gocalc
+
+import "testing"
+
+var printerTests = []struct {
+	expr   string
+	expect string
+}{
+	{"1+2*3", "1 + 2 * 3"},
+	{"a==b = c", "a == b = c"},
+	{"0<=x<10", "0 <= x < 10"},
+	{"(a < b) < c", "(a < b) < c"},
+	{"a < (b < c)", "a < (b < c)"},
+	{"(a < b) == (c > d > e)", "(a < b) == (c > d > e)"},
+	{"a < b + 1 < c in d", "a < b + 1 < c in d"},
+	{"not a and b or not(c or d)", "not a and b or not (c or d)"},
+	{"a not in b", "a not in b"},
+	{"not a > b", "not a > b"},
+	{"(not a) > b", "(not a) > b"},
+	{"not (a and b) == c", "not (a and b) == c"},
+	{"(a between b and c + 1) in d", "a between b and c + 1 in d"},
+	{"a < (b not between (c or d) and e)", "a < (b not between (c or d) and e)"},
+	{"let x = a not in b in x", "let x = a not in b in x"},
+	{"(1 + 2) * 3", "(1 + 2) * 3"},
+	{"1 - (2 - 3)", "1 - (2 - 3)"},
+	{"(1 - 2) - 3", "1 - 2 - 3"},
+	{"((a))", "a"},
+	{"-(a + b)", "-(a + b)"},
+	{"-a.b", "-a.b"},
+	{"(-a).b", "(-a).b"},
+	{"!!true", "!!true"},
+	{"a = b is null", "a = b is null"},
+	{"a = (b is not null)", "a = (b is not null)"},
+	{"(a || b) is null", "(a || b) is null"},
+	{"x in [1, 2.5, \"a\\n\"] && y", "x in [1, 2.5, \"a\\n\"] && y"},
+	{"1. + 2.50 + 3i + 7u", "1.0 + 2.5 + 3i + 7u"},
+	{"(1).x + (1 + 2)[0]", "(1).x + (1 + 2)[0]"},
+	{"f(a, g()) + m[\"k\"]", "f(a, g()) + m[\"k\"]"},
+	{"{a: 1, \"b c\": {}, \"in\": null}", "{a: 1, \"b c\": {}, \"in\": null}"},
+	{"{}.x", "{}.x"},
+	{"map(xs, (x) => x * 2)", "map(xs, x => x * 2)"},
+	{"reduce(xs, 0, (a,b)=>a+b)", "reduce(xs, 0, (a, b) => a + b)"},
+	{"(x => x) + 1", "(x => x) + 1"},
+	{"1 + (x => x)", "1 + (x => x)"},
+	{"f(() => [x => 1])", "f(() => [x => 1])"},
+	{"let x=1,y=(2 in z) in x in y", "let x = 1, y = (2 in z) in x in y"},
+	{"let f = x => (x in y) in [a in b]", "let f = x => (x in y) in [a in b]"},
+	{"(let x = 1 in x) * 2", "(let x = 1 in x) * 2"},
+	{"let a = let b = 1 in b in a", "let a = let b = 1 in b in a"},
+}
+
+// dump returns the serialized tree of e.
+func dump(e *Expression) string {
+	s := newSerializer()
+	e.tree.accept(s)
+	return string(s.buffer)
+}
+
+func TestPrinter(t *testing.T) {
+	for _, test := range printerTests {
+		e, err := NewExpr(test.expr)
+		if err != nil {
+			t.Errorf("Expression \"%v\": Cannot test; lexer or parser error: %v", test.expr, err)
+			continue
+		}
+		if s := e.String(); s != test.expect {
+			t.Errorf("Expression \"%v\": Printed %v, expected %v", test.expr, s, test.expect)
+		}
+	}
+}
+
+func TestPrinterRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		expr string
+		opts []Option
+	}{
+		{"a.b[c - 1] * -(2 % 3) << 1 | ~4 ^ 5 & 6", nil},
+		{"1 >= 2 != (3 < 4) || !(x <= y) && z > 1", nil},
+		{"0.1 + 1. + 2.50", []Option{WithDecimal(2, RoundHalfEven)}},
+		{"(1 << 100) / 3", []Option{WithBigNumbers(false)}},
+		{"{k: [1, {}], \"日本\": \"\\t\"}.k[0] in [] is not null", nil},
+		{"-(x => y => x - y) * f(z => (z, w) => !z)", nil},
+		{"x := 1\ny := [x,\n 2] ; x + y[0]", []Option{WithProgram()}},
+		{"let a = (1 in b) || f(c in d), e = {k: x in y}[g in h] in a in e", nil},
+	} {
+		e, err := NewExpr(test.expr, test.opts...)
+		if err != nil {
+			t.Fatalf("Expression \"%v\": %v", test.expr, err)
+		}
+		printed, err := NewExpr(e.String(), test.opts...)
+		if err != nil {
+			t.Errorf("Expression \"%v\": Printed %v, which does not compile: %v", test.expr, e, err)
+		} else if dump(printed) != dump(e) {
+			t.Errorf("Expression \"%v\": Printed %v, which compiles to a different tree", test.expr, e)
+		}
+	}
+}
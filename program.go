@@ -0,0 +1,39 @@
+package gocalc
+
+// visitProgramExpr evaluates the statements of a program in order. The
+// names they assign are left in e.scope, for EvaluateBindings to collect.
+func (e *evaluator) visitProgramExpr(p *programExpr) {
+	var result interface{}
+	for _, stmt := range p.stmts {
+		result = e.evaluate(stmt)
+	}
+	e.result = result
+}
+
+// visitAssignExpr binds a name to the value of an assignment in the scope
+// of the statements that follow it, and also yields the value.
+func (e *evaluator) visitAssignExpr(a *assignExpr) {
+	v := e.evaluate(a.value)
+	e.scope = &scope{a.name, v, e.scope}
+	e.result = v
+}
+
+// bindings returns the latest value bound to each name in e.scope, leaving
+// out lambdas, which are not values, and lists and maps holding them.
+func (e *evaluator) bindings() map[string]interface{} {
+	r := map[string]interface{}{}
+	for s := e.scope; s != nil; s = s.parent {
+		if _, ok := r[s.name]; ok {
+			continue
+		}
+		r[s.name] = s.value
+	}
+	for name, v := range r {
+		if lambdaIn(v) != nil {
+			delete(r, name)
+		} else if e.config.demote {
+			r[name] = demote(v)
+		}
+	}
+	return r
+}
@@ -0,0 +1,130 @@
+package gocalc
+
+import (
+	"fmt"
+	"testing"
+)
+
+var programTests = []struct {
+	ok       bool
+	src      string
+	expect   string
+	bindings string
+}{
+	{true, "base := price * qty; tax := base * 0.25; base + tax", "50", "map[base:40 tax:10]"},
+	{true, "base := price * qty\ntax := base * 0.25\nbase + tax\n", "50", "map[base:40 tax:10]"},
+	{true, "x := 1; x := x + 1; x * 10", "20", "map[x:2]"},
+	{true, "price := price + 1; price", "11", "map[price:11]"},
+	{true, "x := 5", "5", "map[x:5]"},
+	{true, "1 + 2", "3", "map[]"},
+	{true, ";\n x := 1;;\n\n x ;", "1", "map[x:1]"},
+	{true, "total := 1 +\n  2 *\n  3\ntotal", "7", "map[total:7]"},
+	{true, "xs := [\n  1,\n  2\n]\nsum(xs)", "3", "map[xs:[1 2]]"},
+	{true, "f(\n  qty\n)", "4", "map[]"},
+	{true, "x := 1\n-2", "-2", "map[x:1]"},
+	{true, "xs := [1]\n[2]", "[2]", "map[xs:[1]]"},
+	{true, "x := qty\n(1 + 2) * 3", "9", "map[x:4]"},
+	{true, "g := x => x + 1\ng\n(2)", "2", "map[]"},
+	{true, "double := x => x * 2; double(qty)", "8", "map[]"},
+	{true, "fs := [x => x, x => -x]; map([qty], fs[1])", "[-4]", "map[]"},
+	{true, "ops := {neg: x => -x}; n := 1; map([n], ops.neg)", "[-1]", "map[n:1]"},
+	{true, "n := 3; map([1, 2], x => x + n)", "[4 5]", "map[n:3]"},
+	{true, "y := let a = 2 in a * a\ny", "4", "map[y:4]"},
+	{false, "x := 1 / 0; 2", "", ""},
+	{false, "x := y; 2", "", ""},
+}
+
+func TestProgram(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"price": 10.0,
+		"qty":   4,
+	})
+	h := func(fn string, args ...func() interface{}) (interface{}, bool) {
+		if fn == "f" {
+			return args[0](), true
+		}
+		return nil, false
+	}
+
+	for _, test := range programTests {
+		e, err := NewExpr(test.src, WithProgram())
+		if err != nil {
+			t.Errorf("Program %q: Cannot test; lexer or parser error: %v", test.src, err)
+			continue
+		}
+
+		res, bindings, err := e.EvaluateBindings(params, h)
+		if test.ok && err != nil {
+			t.Errorf("Program %q: Evaluation failed with error: %v", test.src, err)
+		} else if s := fmt.Sprint(res); test.ok && s != test.expect {
+			t.Errorf("Program %q: Evaluation returned %v (%T), expected %v", test.src, res, res, test.expect)
+		} else if s := fmt.Sprint(bindings); test.ok && s != test.bindings {
+			t.Errorf("Program %q: Evaluation bound %v, expected %v", test.src, s, test.bindings)
+		} else if !test.ok && err == nil {
+			t.Errorf("Program %q: Evaluation passed but should have failed", test.src)
+		}
+	}
+}
+
+func TestProgramDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		src   string
+		codes []string
+	}{
+		{"x := 1; x", nil},
+		{"", []string{CodeUnexpectedToken}},
+		{";\n;", []string{CodeUnexpectedToken}},
+		{"x := 1 2; y := 3 4", []string{CodeExpectedEnd, CodeExpectedEnd}},
+		{"1 := 2", []string{CodeUnexpectedToken}},
+		{"x := (1\ny := 2", []string{CodeUnexpectedToken}},
+		{"x := ; 1 +", []string{CodeUnexpectedToken, CodeUnexpectedToken}},
+		{"1\n] 2\n,", []string{CodeUnexpectedToken, CodeUnexpectedToken}},
+	} {
+		diags := Check(test.src, WithProgram())
+		if len(diags) != len(test.codes) {
+			t.Errorf("Check of %q: expected %d diagnostics, got %d: %v", test.src, len(test.codes), len(diags), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Code != test.codes[i] {
+				t.Errorf("Check of %q: diagnostic %d: expected code %s, got %s (%v)", test.src, i, test.codes[i], d.Code, d)
+			}
+		}
+	}
+}
+
+func TestProgramStatementsNeedProgramMode(t *testing.T) {
+	for _, src := range []string{"x := 1", "1; 2"} {
+		if _, err := NewExpr(src); err == nil {
+			t.Errorf("Expression %q compiled without WithProgram", src)
+		}
+	}
+}
+
+func TestProgramBindingsDemoted(t *testing.T) {
+	e, _ := NewExpr("x := 1 << 70; y := x >> 68; y", WithProgram(), WithBigNumbers(true))
+	res, bindings, err := e.EvaluateBindings(nil, nil)
+	if err != nil {
+		t.Fatalf("Evaluation failed with error: %v", err)
+	}
+	if res != int64(4) || bindings["y"] != int64(4) || fmt.Sprint(bindings["x"]) != "1180591620717411303424" {
+		t.Errorf("Evaluation returned %v and bound %v", res, bindings)
+	}
+}
+
+func ExampleExpression_EvaluateBindings() {
+	program, _ := NewExpr(`
+		base := price * qty
+		tax := base * 0.13
+		base + tax
+	`, WithProgram(), WithDecimal(2, RoundHalfEven))
+
+	result, bindings, _ := program.EvaluateBindings(MapEnv(map[string]interface{}{
+		"price": 12.5,
+		"qty":   4,
+	}), nil)
+	fmt.Println(result, bindings["tax"])
+
+	// Output:
+	// 56.500 6.500
+}
@@ -3,6 +3,7 @@ package gocalc
 import (
 	"fmt"
 	"io"
+	"strings"
 )
 
 type serializer struct {
@@ -50,6 +51,25 @@ func (s *serializer) visitBinaryExpr(b *binaryExpr) {
 	s.println("}")
 }
 
+func (s *serializer) visitCompareExpr(c *compareExpr) {
+	s.println("*compareExpr {")
+	s.indent++
+	s.printf("operands (len: %d) {\n", len(c.operands))
+	s.indent++
+	for _, operand := range c.operands {
+		operand.accept(s)
+	}
+	s.indent--
+	s.println("}")
+	ops := make([]string, len(c.ops))
+	for i, op := range c.ops {
+		ops[i] = op.val
+	}
+	s.printf("ops: %s\n", strings.Join(ops, " "))
+	s.indent--
+	s.println("}")
+}
+
 func (s *serializer) visitFuncExpr(f *funcExpr) {
 	s.println("*funcExpr {")
 	s.indent++
@@ -76,6 +96,91 @@ func (s *serializer) visitUnaryExpr(u *unaryExpr) {
 	s.println("}")
 }
 
+func (s *serializer) visitListExpr(l *listExpr) {
+	s.println("*listExpr {")
+	s.indent++
+	s.printf("elems (len: %d) {\n", len(l.elems))
+	s.indent++
+	for _, elem := range l.elems {
+		elem.accept(s)
+	}
+	s.indent--
+	s.println("}")
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitMapExpr(m *mapExpr) {
+	s.println("*mapExpr {")
+	s.indent++
+	s.printf("entries (len: %d) {\n", len(m.keys))
+	s.indent++
+	for i, key := range m.keys {
+		s.printf("%q: ", key)
+		s.ignore = true
+		m.values[i].accept(s)
+	}
+	s.indent--
+	s.println("}")
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitLambdaExpr(l *lambdaExpr) {
+	s.println("*lambdaExpr {")
+	s.indent++
+	s.printf("params: %s\n", strings.Join(l.params, ", "))
+	s.printf("body: ")
+	s.ignore = true
+	l.body.accept(s)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitLetExpr(l *letExpr) {
+	s.println("*letExpr {")
+	s.indent++
+	s.printf("bindings (len: %d) {\n", len(l.names))
+	s.indent++
+	for i, name := range l.names {
+		s.printf("%s: ", name)
+		s.ignore = true
+		l.values[i].accept(s)
+	}
+	s.indent--
+	s.println("}")
+	s.printf("body: ")
+	s.ignore = true
+	l.body.accept(s)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitProgramExpr(p *programExpr) {
+	s.println("*programExpr {")
+	s.indent++
+	s.printf("stmts (len: %d) {\n", len(p.stmts))
+	s.indent++
+	for _, stmt := range p.stmts {
+		stmt.accept(s)
+	}
+	s.indent--
+	s.println("}")
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitAssignExpr(a *assignExpr) {
+	s.println("*assignExpr {")
+	s.indent++
+	s.printf("name: %s\n", a.name)
+	s.printf("value: ")
+	s.ignore = true
+	a.value.accept(s)
+	s.indent--
+	s.println("}")
+}
+
 func (s *serializer) visitBoolExpr(b *boolExpr) {
 	s.println("*boolExpr {")
 	s.indent++
@@ -84,6 +189,10 @@ func (s *serializer) visitBoolExpr(b *boolExpr) {
 	s.println("}")
 }
 
+func (s *serializer) visitNullExpr(n *nullExpr) {
+	s.println("*nullExpr {}")
+}
+
 func (s *serializer) visitFloatExpr(f *floatExpr) {
 	s.println("*floatExpr {")
 	s.indent++
@@ -92,6 +201,22 @@ func (s *serializer) visitFloatExpr(f *floatExpr) {
 	s.println("}")
 }
 
+func (s *serializer) visitComplexExpr(c *complexExpr) {
+	s.println("*complexExpr {")
+	s.indent++
+	s.printf("val: %v\n", c.val)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitDecimalExpr(d *decimalExpr) {
+	s.println("*decimalExpr {")
+	s.indent++
+	s.printf("val: %s\n", d.val)
+	s.indent--
+	s.println("}")
+}
+
 func (s *serializer) visitIntExpr(i *intExpr) {
 	s.println("*intExpr {")
 	s.indent++
@@ -108,6 +233,110 @@ func (s *serializer) visitParamExpr(e *paramExpr) {
 	s.println("}")
 }
 
+func (s *serializer) visitUintExpr(u *uintExpr) {
+	s.println("*uintExpr {")
+	s.indent++
+	s.printf("val: %d\n", u.val)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitBigIntExpr(b *bigIntExpr) {
+	s.println("*bigIntExpr {")
+	s.indent++
+	s.printf("val: %s\n", b.val)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitDurationExpr(d *durationExpr) {
+	s.println("*durationExpr {")
+	s.indent++
+	s.printf("val: %s\n", d.val)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitQuantityExpr(q *quantityExpr) {
+	s.println("*quantityExpr {")
+	s.indent++
+	s.printf("x: ")
+	s.ignore = true
+	q.x.accept(s)
+	s.printf("unit: %s\n", q.unit)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitStringExpr(e *stringExpr) {
+	s.println("*stringExpr {")
+	s.indent++
+	s.printf("val: %q\n", e.val)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitSelectorExpr(e *selectorExpr) {
+	s.println("*selectorExpr {")
+	s.indent++
+	s.printf("x: ")
+	s.ignore = true
+	e.x.accept(s)
+	s.printf("sel: %s\n", e.sel)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitIndexExpr(e *indexExpr) {
+	s.println("*indexExpr {")
+	s.indent++
+	s.printf("x: ")
+	s.ignore = true
+	e.x.accept(s)
+	s.printf("index: ")
+	s.ignore = true
+	e.index.accept(s)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitIsNullExpr(e *isNullExpr) {
+	s.println("*isNullExpr {")
+	s.indent++
+	s.printf("x: ")
+	s.ignore = true
+	e.x.accept(s)
+	s.printf("not: %t\n", e.not)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitBetweenExpr(e *betweenExpr) {
+	s.println("*betweenExpr {")
+	s.indent++
+	s.printf("x: ")
+	s.ignore = true
+	e.x.accept(s)
+	s.printf("not: %t\n", e.not)
+	s.printf("low: ")
+	s.ignore = true
+	e.low.accept(s)
+	s.printf("high: ")
+	s.ignore = true
+	e.high.accept(s)
+	s.indent--
+	s.println("}")
+}
+
+func (s *serializer) visitBadExpr(b *badExpr) {
+	s.println("*badExpr {")
+	s.indent++
+	s.printf("pos: %d\n", b.pos)
+	s.printf("end: %d\n", b.end)
+	s.indent--
+	s.println("}")
+}
+
 var indent = []byte(".   ")
 
 func (s *serializer) printIndent() {
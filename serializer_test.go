@@ -12,7 +12,7 @@ func (d devNull) Write([]byte) (int, error) {
 }
 
 func TestSerializer(t *testing.T) {
-	p := newParser(newLexer("((1 + abs(-5)) > 1.0 + a) || (a > 2 && false)"))
+	p := newParser(newLexer("((1 + abs(-5)) > 1.0 + a) || (a > 2 && false)", nil))
 	e := p.parseExpr()
 	s := newSerializer()
 	e.accept(s)
@@ -0,0 +1,323 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits matches the units of a duration literal, with the numbers
+// between them: the "h30m" of 1h30m. A week, w, is seven days, and a day, d,
+// 24 hours.
+var durationUnits = regexp.MustCompile(`^(ns|µs|ms|s|m|h|d|w)([0-9]+(ns|µs|ms|s|m|h|d|w))*$`)
+
+// durationUnit is the length of each unit of a duration literal.
+var durationUnit = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// durationPart matches a number and unit of a duration.
+var durationPart = regexp.MustCompile(`([0-9]+(?:\.[0-9]*)?)(ns|µs|ms|s|m|h|d|w)`)
+
+// parseDuration parses a duration written like a duration literal, as a
+// sequence of numbers each followed by a unit.
+func parseDuration(s string) (time.Duration, error) {
+	parts := durationPart.FindAllStringSubmatchIndex(s, -1)
+	if len(parts) == 0 || parts[0][0] != 0 || parts[len(parts)-1][1] != len(s) {
+		return 0, fmt.Errorf("Invalid duration %q", s)
+	}
+	var d time.Duration
+	for i, part := range parts {
+		if i > 0 && part[0] != parts[i-1][1] {
+			return 0, fmt.Errorf("Invalid duration %q", s)
+		}
+		number, unit := s[part[2]:part[3]], durationUnit[s[part[4]:part[5]]]
+		var n time.Duration
+		if i, err := strconv.ParseInt(number, 10, 64); err == nil && i <= math.MaxInt64/int64(unit) {
+			n = time.Duration(i) * unit
+		} else if f, err := strconv.ParseFloat(number, 64); err == nil && f*float64(unit) < math.MaxInt64 {
+			n = time.Duration(math.Round(f * float64(unit)))
+		} else {
+			return 0, fmt.Errorf("Duration %q out of range", s)
+		}
+		if d > math.MaxInt64-n {
+			return 0, fmt.Errorf("Duration %q out of range", s)
+		}
+		d += n
+	}
+	return d, nil
+}
+
+// formatDuration formats the non-negative duration d like a duration
+// literal, in the largest units that represent it exactly.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+	var b strings.Builder
+	for _, unit := range []string{"d", "h", "m", "s", "ms", "µs", "ns"} {
+		if n := d / durationUnit[unit]; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit)
+			d -= n * durationUnit[unit]
+		}
+	}
+	return b.String()
+}
+
+// temporal applies op to left and right if either is a time.Time or a
+// time.Duration, or returns nil. Times and durations can be added and
+// subtracted as their meanings allow, durations scaled by numbers and
+// divided by one another, and both compared with their own kind.
+func (e *evaluator) temporal(op *token, left, right interface{}) interface{} {
+	switch l := left.(type) {
+	case time.Time:
+		switch r := right.(type) {
+		case time.Time:
+			if op.typ == tokenMinus {
+				return l.Sub(r)
+			}
+			return compareBinary(op, l.Compare(r))
+		case time.Duration:
+			switch op.typ {
+			case tokenPlus:
+				return l.Add(r)
+			case tokenMinus:
+				return l.Add(-r)
+			}
+		}
+	case time.Duration:
+		switch r := right.(type) {
+		case time.Duration:
+			return e.durationBinary(op, l, r)
+		case time.Time:
+			if op.typ == tokenPlus {
+				return r.Add(l)
+			}
+		default:
+			return e.scale(op, l, r)
+		}
+	default:
+		if r, ok := right.(time.Duration); ok && op.typ == tokenStar {
+			return e.scale(op, r, l)
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) durationBinary(op *token, l, r time.Duration) interface{} {
+	switch op.typ {
+	case tokenPlus:
+		if sum := l + r; (sum > l) == (r > 0) {
+			return sum
+		}
+		e.error("Duration %v %s %v out of range", l, op, r)
+	case tokenMinus:
+		if diff := l - r; (diff < l) == (r > 0) {
+			return diff
+		}
+		e.error("Duration %v %s %v out of range", l, op, r)
+	case tokenSlash:
+		if r == 0 {
+			e.error("Duration division by zero")
+		}
+		return e.adapt(float64(l) / float64(r))
+	case tokenPercent:
+		if r == 0 {
+			e.error("Duration division by zero")
+		}
+		return l % r
+	case tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual:
+		switch {
+		case l < r:
+			return compareBinary(op, -1)
+		case l > r:
+			return compareBinary(op, 1)
+		}
+		return compareBinary(op, 0)
+	}
+	return nil
+}
+
+// scale multiplies or divides the duration d by the real number n, or
+// returns nil if n is not one.
+func (e *evaluator) scale(op *token, d time.Duration, n interface{}) interface{} {
+	if op.typ != tokenStar && op.typ != tokenSlash {
+		return nil
+	}
+	if i, ok := demote(n).(int64); ok {
+		if op.typ == tokenSlash && i == 0 {
+			e.error("Duration division by zero")
+		}
+		// The most negative duration has no positive counterpart.
+		if d == math.MinInt64 && i == -1 {
+			e.error("Duration %v %s %v out of range", d, op, i)
+		}
+		if op.typ == tokenSlash {
+			return d / time.Duration(i)
+		}
+		if p := d * time.Duration(i); i == 0 || p/time.Duration(i) == d {
+			return p
+		}
+		e.error("Duration %v %s %v out of range", d, op, i)
+	}
+	c, ok := toComplex(n)
+	if !ok || rank(n) == rankComplex {
+		return nil
+	}
+	f := real(c)
+	if op.typ == tokenSlash {
+		if f == 0 {
+			e.error("Duration division by zero")
+		}
+		f = 1 / f
+	}
+	if r := float64(d) * f; math.Abs(r) < math.MaxInt64 {
+		return time.Duration(math.Round(r))
+	}
+	e.error("Duration %v * %v out of range", d, f)
+	return nil
+}
+
+// now returns the time given by the clock of the Expression, which is read
+// once per evaluation so that every call of now() agrees.
+func (e *evaluator) now() time.Time {
+	if e.time.IsZero() {
+		clock := e.config.clock
+		if clock == nil {
+			clock = time.Now
+		}
+		e.time = clock()
+	}
+	return e.time
+}
+
+// timeArg returns args[i] as a time.
+func (e *evaluator) timeArg(f *funcExpr, args []interface{}, i int) time.Time {
+	t, ok := args[i].(time.Time)
+	if !ok {
+		e.error("%s requires a time argument %d, got %v (%T)", f.function, i+1, args[i], args[i])
+	}
+	return t
+}
+
+// stringArg returns args[i] as a string.
+func (e *evaluator) stringArg(f *funcExpr, args []interface{}, i int) string {
+	s, ok := args[i].(string)
+	if !ok {
+		e.error("%s requires a string argument %d, got %v (%T)", f.function, i+1, args[i], args[i])
+	}
+	return s
+}
+
+// parseTime parses the string args[0] with the layout args[1], if there is
+// one, or else with the first of layouts that it matches. Times without a
+// time zone are in UTC.
+func (e *evaluator) parseTime(f *funcExpr, args []interface{}, layouts ...string) time.Time {
+	s := e.stringArg(f, args, 0)
+	if len(args) > 1 {
+		layouts = []string{e.stringArg(f, args, 1)}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	e.error("%s cannot parse %q as a %s", f.function, s, f.function)
+	return time.Time{}
+}
+
+// now() returns the current time, as given by the clock set with WithClock.
+func builtinNow(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.now()
+}
+
+// date(s) or date(s, layout) returns the time at the start of the date s,
+// which is written like 2006-01-02 or else laid out like layout, as in Go's
+// time.Parse. date(t) returns the time at the start of the date of t.
+func builtinDate(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if t, ok := args[0].(time.Time); ok && len(args) == 1 {
+		r, _ := startOf(t, "day")
+		return r
+	}
+	return e.parseTime(f, args, "2006-01-02")
+}
+
+// datetime(s) or datetime(s, layout) returns the time s, which is written
+// like 2006-01-02T15:04:05Z07:00, with an optional fraction of a second and
+// time zone, or else laid out like layout, as in Go's time.Parse.
+func builtinDatetime(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	return e.parseTime(f, args, time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999")
+}
+
+// duration(s) returns the duration s, which is written like a duration
+// literal, or duration(q) the quantity of time q as a duration.
+func builtinDuration(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	if q, ok := args[0].(Quantity); ok {
+		return e.asDuration(q)
+	}
+	d, err := parseDuration(e.stringArg(f, args, 0))
+	if err != nil {
+		e.error("%s", err)
+	}
+	return d
+}
+
+// timePart returns a builtin returning the component of its time argument
+// that part returns.
+func timePart(part func(t time.Time) int) strictFunc {
+	return func(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+		return e.adapt(int64(part(e.timeArg(f, args, 0))))
+	}
+}
+
+// isoWeekday returns the day of the week of t, from 1 for Monday to 7 for
+// Sunday.
+func isoWeekday(t time.Time) int {
+	if w := t.Weekday(); w != time.Sunday {
+		return int(w)
+	}
+	return 7
+}
+
+// startOf(t, unit) returns the start of the year, month, week, day, hour or
+// minute that t is in, as given by unit. Weeks start on Mondays.
+func builtinStartOf(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	t := e.timeArg(f, args, 0)
+	unit := e.stringArg(f, args, 1)
+	r, ok := startOf(t, unit)
+	if !ok {
+		e.error("%s requires a unit of year, month, week, day, hour or minute, got %q", f.function, unit)
+	}
+	return r
+}
+
+// startOf returns the start of the unit of time that t is in. ok is false
+// if unit is not a unit of time.
+func startOf(t time.Time, unit string) (r time.Time, ok bool) {
+	y, m, d := t.Date()
+	switch unit {
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location()), true
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location()), true
+	case "week":
+		return time.Date(y, m, d-isoWeekday(t)+1, 0, 0, 0, 0, t.Location()), true
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), true
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location()), true
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, t.Location()), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,186 @@
+package gocalc
+
+import (
+	"testing"
+	"time"
+)
+
+var clockTime = time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+
+var timeTests = []expressionTest{
+	// Duration literals
+	{true, "90s", 90 * time.Second},
+	{true, "90s == 1m30s", true},
+	{true, "5m", 5 * time.Minute},
+	{true, "48h", 48 * time.Hour},
+	{true, "30d", 30 * 24 * time.Hour},
+	{true, "2w == 14d", true},
+	{true, "1.5h", 90 * time.Minute},
+	{true, "1h30m15s", time.Hour + 30*time.Minute + 15*time.Second},
+	{true, "250ms + 500µs + 10ns", 250*time.Millisecond + 500*time.Microsecond + 10},
+	{true, "0d", time.Duration(0)},
+
+	// Duration arithmetic
+	{true, "1h - 90m", -30 * time.Minute},
+	{true, "-1h", -time.Hour},
+	{true, "+1h", time.Hour},
+	{true, "1h * 3", 3 * time.Hour},
+	{true, "15000w - 15000w", time.Duration(0)},
+	{true, "-15000w + 15000w", time.Duration(0)},
+	{true, "15000w * -1", -15000 * 7 * 24 * time.Hour},
+	{true, "3 * 1h", 3 * time.Hour},
+	{true, "1h * 1.5", 90 * time.Minute},
+	{true, "1h / 4", 15 * time.Minute},
+	{true, "1h / 0.5", 2 * time.Hour},
+	{true, "1h / 30m", 2.0},
+	{true, "100m % 1h", 40 * time.Minute},
+	{true, "1h > 59m", true},
+	{true, "1h <= 59m", false},
+	{true, "max(1h, 30m, 2h)", 2 * time.Hour},
+	{true, "sort([1h, 1m, 1s])[0]", time.Second},
+	{true, "sum([1h, 30m])", 90 * time.Minute},
+	{true, "sum(1s)", time.Second},
+	{true, "avg([1s, 2s])", 1500 * time.Millisecond},
+	{true, "1h == 3600", false},
+	{true, "1h + null", Null},
+
+	// Times
+	{true, `date("2026-01-01") + 30d`, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)},
+	{true, `date("2026-01-01") + 30d == date("2026-01-31")`, true},
+	{true, `30d + date("2026-01-01") == date("2026-01-31")`, true},
+	{true, `date("2026-01-31") - date("2026-01-01")`, 30 * 24 * time.Hour},
+	{true, `date("2026-01-31") - 1w`, time.Date(2026, 1, 24, 0, 0, 0, 0, time.UTC)},
+	{true, `date("01/02/2026", "01/02/2006")`, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+	{true, `date(now())`, time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+	{true, `datetime("2026-03-14T15:09:26Z") == now()`, true},
+	{true, `datetime("2026-03-14T16:09:26+01:00") == now()`, true},
+	{true, `datetime("2026-03-14 15:09:26.5") - now()`, 500 * time.Millisecond},
+	{true, `datetime("2026-03-14T15:09:26")`, clockTime},
+	{true, "now() - created > 48h", true},
+	{true, "now() - created", 71*time.Hour + 9*time.Minute + 26*time.Second},
+	{true, "now() > created", true},
+	{true, "created < now() < created + 1w", true},
+	{true, "created == datetime(\"2026-03-11T16:00:00Z\")", true},
+	{true, "created in [now(), created]", true},
+	{true, "now() - timeout", time.Date(2026, 3, 14, 15, 4, 26, 0, time.UTC)},
+	{true, "timeout", 5 * time.Minute},
+	{true, "pointer", clockTime},
+
+	// Components
+	{true, "year(now())", int64(2026)},
+	{true, "month(now())", int64(3)},
+	{true, "day(now())", int64(14)},
+	{true, "weekday(now())", int64(6)},
+	{true, `weekday(date("2026-03-15"))`, int64(7)},
+	{true, "hour(now())", int64(15)},
+	{true, "minute(now())", int64(9)},
+	{true, `startOf(now(), "year")`, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	{true, `startOf(now(), "month")`, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+	{true, `startOf(now(), "week")`, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+	{true, `startOf(date("2026-03-09"), "week")`, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+	{true, `startOf(date("2026-03-01"), "week")`, time.Date(2026, 2, 23, 0, 0, 0, 0, time.UTC)},
+	{true, `startOf(now(), "day")`, time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+	{true, `startOf(now(), "hour")`, time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)},
+	{true, `startOf(now(), "minute")`, time.Date(2026, 3, 14, 15, 9, 0, 0, time.UTC)},
+	{true, `duration("1h30m")`, 90 * time.Minute},
+	{true, `year(null)`, Null},
+
+	// Errors
+	{false, "2hours", nil},
+	{false, "1h30", nil},
+	{false, "0x1h", nil},
+	{false, "99999999999w", nil},
+	{false, "now() + now()", nil},
+	{false, "now() * 2", nil},
+	{false, "2 / 1h", nil},
+	{false, "1h + 1", nil},
+	{false, "sum([1h, 1])", nil},
+	{false, "sum([now()])", nil},
+	{false, "1h / 0", nil},
+	{false, "1h / 0s", nil},
+	{false, "1h * 2i", nil},
+	{false, "300w * 100000", nil},
+	{false, "100000 * 300w", nil},
+	{false, "15000w + 15000w", nil},
+	{false, "-15000w - 15000w", nil},
+	{false, "(-9223372036854775807ns - 1ns) * -1", nil},
+	{false, "(-9223372036854775807ns - 1ns) / -1", nil},
+	{false, "1h < now()", nil},
+	{false, `date("2026-02-30")`, nil},
+	{false, `date("2026-01-01T00:00:00Z")`, nil},
+	{false, `date(1)`, nil},
+	{false, `startOf(now(), "decade")`, nil},
+	{false, `year(1h)`, nil},
+	{false, `duration("1y")`, nil},
+}
+
+func TestTime(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"created": time.Date(2026, 3, 11, 16, 0, 0, 0, time.UTC),
+		"timeout": 5 * time.Minute,
+		"pointer": &clockTime,
+	})
+	clock := func() time.Time { return clockTime }
+
+	for _, test := range timeTests {
+		checkEvaluation(t, test, params, nil, WithClock(clock))
+	}
+}
+
+func TestClockReadOncePerEvaluation(t *testing.T) {
+	calls := 0
+	clock := func() time.Time {
+		calls++
+		return clockTime.Add(time.Duration(calls) * time.Second)
+	}
+	e, err := NewExpr("now() == now() && let t = now() in map([1, 2], x => t) == [now(), now()]", WithClock(clock))
+	if err != nil {
+		t.Fatalf("Cannot test; lexer or parser error: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		if res, err := e.Evaluate(nil, nil); err != nil || res != true {
+			t.Errorf("Evaluation %d returned %v, %v; expected true", i, res, err)
+		}
+		if calls != i {
+			t.Errorf("Evaluation %d read the clock %d times in all", i, calls)
+		}
+	}
+}
+
+func TestDurationLiterals(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		printed string
+	}{
+		{"90s", "1m30s"},
+		{"1.5h", "1h30m"},
+		{"2w", "14d"},
+		{"1d25h", "2d1h"},
+		{"0d", "0s"},
+		{"1ms500µs", "1ms500µs"},
+		{"-5m * 2", "-5m * 2"},
+	} {
+		e, err := NewExpr(test.expr)
+		if err != nil {
+			t.Errorf("Expression \"%s\": Cannot test; lexer or parser error: %v", test.expr, err)
+			continue
+		}
+		if s := e.String(); s != test.printed {
+			t.Errorf("Expression \"%s\": printed as %q, expected %q", test.expr, s, test.printed)
+		}
+	}
+
+	if diags := Check("99999999999w + 1"); len(diags) != 1 || diags[0].Code != CodeBadLiteral {
+		t.Errorf("Check of an out of range duration: %v", diags)
+	}
+
+	// In math notation, a unit directly after a number is still a duration.
+	params := MapEnv(map[string]interface{}{"h": 10})
+	for _, test := range []expressionTest{
+		{true, "2h", 2 * time.Hour},
+		{true, "2 h", int64(20)},
+		{true, "2(h)", int64(20)},
+	} {
+		checkEvaluation(t, test, params, nil, WithMathNotation())
+	}
+}
@@ -12,17 +12,35 @@ const (
 	tokenIdentifier
 	tokenTrue
 	tokenFalse
+	tokenNull
+	tokenLet
 
 	tokenInt
+	tokenUint
 	tokenFloat
+	tokenImaginary
+	tokenString
 
 	tokenLeftParen
 	tokenRightParen
 
 	tokenComma
 
+	tokenDot
+	tokenLeftBracket
+	tokenRightBracket
+	tokenLeftBrace
+	tokenRightBrace
+	tokenColon
+	tokenArrow
+	tokenAssign
+	tokenSemicolon
+
 	tokenLogicalNot
 	tokenBitwiseNot
+	tokenNot      // not, which also begins not in and not between
+	tokenBetween  // between, which is parsed with its and
+	tokenOperator // an operator of the Dialect, which may be binary too
 
 	tokenBinary
 
@@ -40,9 +58,12 @@ const (
 	tokenLessOrEqual
 	tokenGreaterThan
 	tokenGreaterOrEqual
+	tokenIn
+	tokenNotIn
 
 	tokenEqual
 	tokenNotEqual
+	tokenIs
 
 	tokenBitwiseAnd
 
@@ -55,11 +76,28 @@ const (
 	tokenLogicalOr
 )
 
+// keywords maps the identifiers that are reserved to their token types.
+var keywords = map[string]tokenType{
+	"true":  tokenTrue,
+	"false": tokenFalse,
+	"null":  tokenNull,
+	"let":   tokenLet,
+	"is":    tokenIs,
+	"in":    tokenIn,
+
+	"and":     tokenLogicalAnd,
+	"or":      tokenLogicalOr,
+	"not":     tokenNot,
+	"between": tokenBetween,
+}
+
 type token struct {
-	typ tokenType
-	val string
-	pos int // starting position of token
-	end int // ending position of token
+	typ     tokenType
+	val     string
+	pos     int       // starting position of token
+	end     int       // ending position of token
+	newline bool      // whether a newline precedes the token
+	op      *operator // the Dialect's operator, for tokenOperator
 }
 
 func (t token) String() string {
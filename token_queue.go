@@ -7,11 +7,17 @@ func (q *queue) push(t *token) {
 }
 
 func (q *queue) pop() *token {
+	if len(*q) == 0 {
+		return nil
+	}
 	t := (*q)[0]
 	*q = (*q)[1:]
 	return t
 }
 
 func (q *queue) first() *token {
+	if len(*q) == 0 {
+		return nil
+	}
 	return (*q)[0]
 }
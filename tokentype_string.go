@@ -4,9 +4,9 @@ package gocalc
 
 import "fmt"
 
-const _tokenType_name = "tokenErrortokenWhitespacetokenEOFtokenIdentifiertokenTruetokenFalsetokenInttokenFloattokenLeftParentokenRightParentokenCommatokenLogicalNottokenBitwiseNottokenBinarytokenStartokenSlashtokenPercenttokenPlustokenMinustokenLeftShifttokenRightShifttokenLessThantokenLessOrEqualtokenGreaterThantokenGreaterOrEqualtokenEqualtokenNotEqualtokenBitwiseAndtokenBitwiseXortokenBitwiseOrtokenLogicalAndtokenLogicalOr"
+const _tokenType_name = "tokenErrortokenWhitespacetokenEOFtokenIdentifiertokenTruetokenFalsetokenNulltokenLettokenInttokenUinttokenFloattokenImaginarytokenStringtokenLeftParentokenRightParentokenCommatokenDottokenLeftBrackettokenRightBrackettokenLeftBracetokenRightBracetokenColontokenArrowtokenAssigntokenSemicolontokenLogicalNottokenBitwiseNottokenNottokenBetweentokenOperatortokenBinarytokenStartokenSlashtokenPercenttokenPlustokenMinustokenLeftShifttokenRightShifttokenLessThantokenLessOrEqualtokenGreaterThantokenGreaterOrEqualtokenIntokenNotIntokenEqualtokenNotEqualtokenIstokenBitwiseAndtokenBitwiseXortokenBitwiseOrtokenLogicalAndtokenLogicalOr"
 
-var _tokenType_index = [...]uint16{0, 10, 25, 33, 48, 57, 67, 75, 85, 99, 114, 124, 139, 154, 165, 174, 184, 196, 205, 215, 229, 244, 257, 273, 289, 308, 318, 331, 346, 361, 375, 390, 404}
+var _tokenType_index = [...]uint16{0, 10, 25, 33, 48, 57, 67, 76, 84, 92, 101, 111, 125, 136, 150, 165, 175, 183, 199, 216, 230, 245, 255, 265, 276, 290, 305, 320, 328, 340, 353, 364, 373, 383, 395, 404, 414, 428, 443, 456, 472, 488, 507, 514, 524, 534, 547, 554, 569, 584, 598, 613, 627}
 
 func (i tokenType) String() string {
 	if i < 0 || i+1 >= tokenType(len(_tokenType_index)) {
@@ -0,0 +1,647 @@
+package gocalc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Quantity is a number with a unit of measure, like the value of the
+// literal 5[km]. Resolvers and FuncHandlers may return Quantities, and
+// expressions return them for arithmetic on quantities that has a unit.
+//
+// Value is the magnitude, which may be any real number the evaluator
+// supports, and Unit is written like the unit of a literal: names of units
+// of the Units the Expression uses, each optionally raised to an integer
+// power with ^, separated by * or a space to multiply and by / to divide by
+// the single unit that follows, as in "km", "m/s^2" and "kg m s^-2".
+//
+// Quantities of the same dimension are added, subtracted and compared after
+// converting the right operand to the unit of the left, so 5[km] + 300[m] is
+// 5.3[km], while those of different dimensions, like 5[m] and 2[s], and
+// quantities and plain numbers cannot be. Multiplying and dividing
+// quantities multiplies and divides their units, and a result with no
+// dimension, like 1[km] / 1[m], is a plain number. Converting between units
+// of different sizes computes in floating point, and arithmetic on the
+// magnitudes is otherwise that of numbers.
+//
+// A duration is a quantity of seconds when combined with a Quantity, so
+// 5[h] + 30m is 5.5[h], 30m + 5[h] is the duration 5h30m, and 30m * 2[m/s]
+// is 3600[m].
+//
+type Quantity struct {
+	Value interface{}
+	Unit  string
+}
+
+// String returns the value and unit of q, separated by a space.
+//
+func (q Quantity) String() string {
+	return fmt.Sprintf("%v %s", q.Value, q.Unit)
+}
+
+// Units is a registry of the units of measure that quantities may be
+// written in. Every Units has the SI base and derived units, to which the
+// SI prefixes from p to T apply, as in km, mg and kWh, and the common units
+// min, h, t, L, bar, in, ft, yd, mi, mph, lb and oz. Expressions compiled
+// without WithUnits use those units alone.
+//
+// Units must not be changed while expressions using it are being compiled or
+// evaluated.
+//
+type Units struct {
+	units map[string]*unit
+}
+
+// A unit is a named unit of measure.
+type unit struct {
+	factor float64   // the size of the unit in base units
+	dims   dimension // the powers of the base units it is measured in
+	prefix bool      // whether the SI prefixes apply to it
+}
+
+// A dimension is the power of each base unit of a unit, by name, as in
+// {"kg": 1, "m": 1, "s": -2} for a newton. Powers of zero are left out.
+type dimension map[string]int
+
+func (d dimension) equal(o dimension) bool {
+	if len(d) != len(o) {
+		return false
+	}
+	for base, n := range d {
+		if o[base] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixes are the SI prefixes, with da ahead of d.
+var prefixes = []struct {
+	symbol string
+	factor float64
+}{
+	{"da", 1e1}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6}, {"k", 1e3}, {"h", 1e2},
+	{"d", 1e-1}, {"c", 1e-2}, {"m", 1e-3}, {"µ", 1e-6}, {"u", 1e-6}, {"n", 1e-9}, {"p", 1e-12},
+}
+
+// defaultUnits are the units of expressions compiled without WithUnits.
+var defaultUnits = NewUnits()
+
+// NewUnits returns Units with the SI and common units only.
+//
+func NewUnits() *Units {
+	u := &Units{units: map[string]*unit{}}
+	for _, base := range []string{"m", "s", "A", "K", "mol", "cd"} {
+		u.units[base] = &unit{1, dimension{base: 1}, true}
+	}
+	// The kilogram is the base unit, but the prefixes apply to the gram.
+	u.units["g"] = &unit{1e-3, dimension{"kg": 1}, true}
+	for _, d := range []struct {
+		name   string
+		factor float64
+		of     string
+		prefix bool
+	}{
+		{"Hz", 1, "s^-1", true},
+		{"N", 1, "kg*m/s^2", true},
+		{"Pa", 1, "N/m^2", true},
+		{"J", 1, "N*m", true},
+		{"W", 1, "J/s", true},
+		{"Wh", 3600, "J", true},
+		{"C", 1, "A*s", true},
+		{"V", 1, "W/A", true},
+		{"Ω", 1, "V/A", true},
+		{"ohm", 1, "V/A", true},
+		{"L", 1e-3, "m^3", true},
+		{"min", 60, "s", false},
+		{"h", 3600, "s", false},
+		{"t", 1000, "kg", false},
+		{"bar", 1e5, "Pa", false},
+		{"in", 0.0254, "m", false},
+		{"ft", 0.3048, "m", false},
+		{"yd", 0.9144, "m", false},
+		{"mi", 1609.344, "m", false},
+		{"mph", 1, "mi/h", false},
+		{"lb", 0.45359237, "kg", false},
+		{"oz", 0.028349523125, "kg", false},
+	} {
+		if err := u.add(d.name, d.factor, d.of, d.prefix); err != nil {
+			panic(err)
+		}
+	}
+	return u
+}
+
+// AddBase adds a base unit called name, which measures a dimension of its
+// own, like a currency or a number of pixels, or returns an error if name is
+// invalid or already a unit. The SI prefixes do not apply to it.
+//
+func (u *Units) AddBase(name string) error {
+	if err := u.validName(name); err != nil {
+		return err
+	}
+	u.units[name] = &unit{1, dimension{name: 1}, false}
+	return nil
+}
+
+// Add adds a unit called name that is factor times the unit of, as in
+// Add("nmi", 1852, "m") or Add("knot", 1, "nmi/h"), or returns an error if
+// name is invalid or already a unit, factor is not positive or of is not a
+// unit. The SI prefixes do not apply to it.
+//
+func (u *Units) Add(name string, factor float64, of string) error {
+	return u.add(name, factor, of, false)
+}
+
+func (u *Units) add(name string, factor float64, of string, prefix bool) error {
+	if err := u.validName(name); err != nil {
+		return err
+	}
+	if !(factor > 0) || math.IsInf(factor, 1) {
+		return fmt.Errorf("Unit %q has factor %v, which is not positive", name, factor)
+	}
+	c, err := parseUnit(of)
+	if err != nil {
+		return err
+	}
+	f, dims, err := u.measure(c)
+	if err != nil {
+		return err
+	}
+	u.units[name] = &unit{factor * f, dims, prefix}
+	return nil
+}
+
+// validName returns an error if name cannot be the name of a new unit.
+func (u *Units) validName(name string) error {
+	if !unitName(name) {
+		return fmt.Errorf("Unit %q is not a valid name", name)
+	}
+	if _, ok := u.lookup(name); ok {
+		return fmt.Errorf("Unit %q is already a unit", name)
+	}
+	return nil
+}
+
+// unitName reports whether s is written like an identifier.
+func unitName(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// lookup returns the unit called name, which may be a unit the SI prefixes
+// apply to with one of them in front.
+func (u *Units) lookup(name string) (*unit, bool) {
+	if x, ok := u.units[name]; ok {
+		return x, true
+	}
+	for _, p := range prefixes {
+		if rest := strings.TrimPrefix(name, p.symbol); rest != name {
+			if x, ok := u.units[rest]; ok && x.prefix {
+				return &unit{p.factor * x.factor, x.dims, false}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// measure returns the size in base units and the dimension of the unit c.
+func (u *Units) measure(c compound) (float64, dimension, error) {
+	factor, dims := 1.0, dimension{}
+	for _, p := range c {
+		x, ok := u.lookup(p.name)
+		if !ok {
+			return 0, nil, fmt.Errorf("Unknown unit %q", p.name)
+		}
+		factor *= math.Pow(x.factor, float64(p.exp))
+		for base, n := range x.dims {
+			if dims[base] += n * p.exp; dims[base] == 0 {
+				delete(dims, base)
+			}
+		}
+	}
+	return factor, dims, nil
+}
+
+// A compound is a unit written as a product of powers of named units, in
+// the order they were first written.
+type compound []power
+
+type power struct {
+	name string
+	exp  int
+}
+
+// parseUnit parses the unit s, written like the Unit of a Quantity.
+func parseUnit(s string) (compound, error) {
+	var c compound
+	exp, i := 1, 0
+	skip := func() bool {
+		start := i
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		return i > start
+	}
+	skip()
+	for {
+		start := i
+		for i < len(s) {
+			r, w := utf8.DecodeRuneInString(s[i:])
+			if r != '_' && !unicode.IsLetter(r) && (i == start || !unicode.IsDigit(r)) {
+				break
+			}
+			i += w
+		}
+		if i == start {
+			return nil, fmt.Errorf("Invalid unit %q: expected a unit name at %d", s, i)
+		}
+		name := s[start:i]
+		space := skip()
+		if i < len(s) && s[i] == '^' {
+			i++
+			skip()
+			digits := i
+			if i < len(s) && s[i] == '-' {
+				i++
+			}
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			n, err := strconv.Atoi(s[digits:i])
+			if err != nil || n == 0 || n < -99 || n > 99 {
+				return nil, fmt.Errorf("Invalid unit %q: invalid power of %s", s, name)
+			}
+			exp *= n
+			space = skip()
+		}
+		c = c.times(compound{{name, exp}})
+		switch {
+		case i == len(s):
+			return c, nil
+		case s[i] == '*':
+			exp = 1
+		case s[i] == '/':
+			exp = -1
+		case space:
+			exp = 1
+			continue
+		default:
+			return nil, fmt.Errorf("Invalid unit %q: unexpected %q at %d", s, s[i], i)
+		}
+		i++
+		skip()
+	}
+}
+
+// times returns the product of c and d, in which the powers of units that
+// both have are added and those that become zero left out.
+func (c compound) times(d compound) compound {
+	r := append(compound(nil), c...)
+	for _, p := range d {
+		i := 0
+		for i < len(r) && r[i].name != p.name {
+			i++
+		}
+		switch {
+		case i == len(r):
+			r = append(r, p)
+		case r[i].exp+p.exp == 0:
+			r = append(r[:i], r[i+1:]...)
+		default:
+			r[i].exp += p.exp
+		}
+	}
+	return r
+}
+
+// inverse returns the reciprocal of c.
+func (c compound) inverse() compound {
+	r := make(compound, len(c))
+	for i, p := range c {
+		r[i] = power{p.name, -p.exp}
+	}
+	return r
+}
+
+// String returns c written like the Unit of a Quantity, with the units of
+// positive powers first, as in kg*m/s^2, or the units alone if none are
+// positive, as in s^-1.
+func (c compound) String() string {
+	var b strings.Builder
+	for _, p := range c {
+		if p.exp > 0 {
+			if b.Len() > 0 {
+				b.WriteByte('*')
+			}
+			b.WriteString(p.name)
+			if p.exp != 1 {
+				fmt.Fprintf(&b, "^%d", p.exp)
+			}
+		}
+	}
+	for _, p := range c {
+		switch {
+		case p.exp > 0:
+		case b.Len() == 0:
+			fmt.Fprintf(&b, "%s^%d", p.name, p.exp)
+		default:
+			b.WriteString("/" + p.name)
+			if p.exp != -1 {
+				fmt.Fprintf(&b, "^%d", -p.exp)
+			}
+		}
+	}
+	return b.String()
+}
+
+// unitLabel describes the unit of a value with the unit c in errors.
+func unitLabel(c compound) string {
+	if len(c) == 0 {
+		return "a plain number"
+	}
+	return c.String()
+}
+
+// registry returns the Units of the Expression.
+func (c *config) registry() *Units {
+	if c.units != nil {
+		return c.units
+	}
+	return defaultUnits
+}
+
+// unit returns the unit of q, failing the evaluation if q is not a number
+// with a unit of the Expression.
+func (e *evaluator) unit(q Quantity) compound {
+	if r := rank(q.Value); r == rankNone || r == rankComplex {
+		e.error("Quantity has a value of %v (%T), which is not a real number", q.Value, q.Value)
+	}
+	c, err := parseUnit(q.Unit)
+	e.check(err)
+	_, _, err = e.config.registry().measure(c)
+	e.check(err)
+	return c
+}
+
+// asQuantity returns x as a Quantity if it is one or a duration, which is a
+// quantity of seconds. ok is false if x is neither.
+func (e *evaluator) asQuantity(x interface{}) (q Quantity, ok bool) {
+	switch x := x.(type) {
+	case Quantity:
+		return x, true
+	case time.Duration:
+		if x%time.Second == 0 {
+			return Quantity{e.adapt(int64(x / time.Second)), "s"}, true
+		}
+		return Quantity{e.adapt(x.Seconds()), "s"}, true
+	}
+	return Quantity{}, false
+}
+
+// asDuration returns the quantity of time q as a duration.
+func (e *evaluator) asDuration(q Quantity) time.Duration {
+	from, to := e.unit(q), compound{{"s", 1}}
+	if !e.compatible(from, to) {
+		e.error("Cannot convert %v to a duration", q)
+	}
+	return e.scale(starOp, time.Second, e.convertQuantity(q, from, to)).(time.Duration)
+}
+
+// unitOf returns the unit of x, which is none if x is a number. ok is false
+// if x is neither a number nor a Quantity.
+func (e *evaluator) unitOf(x interface{}) (c compound, ok bool) {
+	if q, ok := x.(Quantity); ok {
+		return e.unit(q), true
+	}
+	if r := rank(x); r == rankNone || r == rankComplex {
+		return nil, false
+	}
+	return nil, true
+}
+
+// quantity applies op to left and right if either is a Quantity, or returns
+// nil.
+func (e *evaluator) quantity(op *token, left, right interface{}) interface{} {
+	_, lok := left.(Quantity)
+	_, rok := right.(Quantity)
+	if !lok && !rok {
+		return nil
+	}
+	_, duration := left.(time.Duration)
+	l, lok := e.asQuantity(left)
+	if lok {
+		left = l
+	}
+	r, rok := e.asQuantity(right)
+	if rok {
+		right = r
+	}
+	lu, ok := e.unitOf(left)
+	if !ok {
+		return nil
+	}
+	ru, ok := e.unitOf(right)
+	if !ok {
+		return nil
+	}
+	switch op.typ {
+	case tokenPlus, tokenMinus, tokenPercent,
+		tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual:
+		if !lok || !rok || !e.compatible(lu, ru) {
+			e.error("Incompatible units for %s: %s and %s", op, unitLabel(lu), unitLabel(ru))
+		}
+		v := e.binary(op, l.Value, e.convertQuantity(r, ru, lu))
+		if relationalOp(op) {
+			return v
+		}
+		if duration {
+			return e.asDuration(Quantity{v, l.Unit})
+		}
+		return Quantity{v, l.Unit}
+	case tokenStar, tokenSlash:
+		lv, rv := left, right
+		if lok {
+			lv = l.Value
+		}
+		if rok {
+			rv = r.Value
+		}
+		if op.typ == tokenSlash {
+			ru = ru.inverse()
+		}
+		c := lu.times(ru)
+		factor, dims, err := e.config.registry().measure(c)
+		e.check(err)
+		if len(dims) > 0 {
+			return Quantity{e.binary(op, lv, rv), c.String()}
+		}
+		// A result with no dimension is a plain number, which is converted
+		// before dividing, so that 1[km] / 3[m] is not 0.
+		if factor != 1 {
+			lv = e.binary(starOp, lv, e.adapt(factor))
+		}
+		return e.binary(op, lv, rv)
+	}
+	return nil
+}
+
+// compatible reports whether the units c and d measure the same dimension.
+func (e *evaluator) compatible(c, d compound) bool {
+	_, cd, err := e.config.registry().measure(c)
+	e.check(err)
+	_, dd, err := e.config.registry().measure(d)
+	e.check(err)
+	return cd.equal(dd)
+}
+
+// convertQuantity returns the value of q, whose unit is from, in the unit
+// to, which measures the same dimension.
+func (e *evaluator) convertQuantity(q Quantity, from, to compound) interface{} {
+	ff, _, err := e.config.registry().measure(from)
+	e.check(err)
+	tf, _, err := e.config.registry().measure(to)
+	e.check(err)
+	if ff == tf {
+		return q.Value
+	}
+	return e.binary(quoOp, e.binary(starOp, q.Value, e.adapt(ff)), e.adapt(tf))
+}
+
+// equalQuantities reports whether the quantities l and r measure the same
+// dimension and are equal once converted to the same unit.
+func (e *evaluator) equalQuantities(l, r Quantity) bool {
+	lu, ru := e.unit(l), e.unit(r)
+	return e.compatible(lu, ru) && e.equal(l.Value, e.convertQuantity(r, ru, lu))
+}
+
+// to(x, unit) returns the quantity x, which may be a duration, converted to
+// unit, which must measure the same dimension.
+func builtinTo(e *evaluator, f *funcExpr, args []interface{}) interface{} {
+	q, ok := e.asQuantity(args[0])
+	if !ok {
+		e.error("%s requires a quantity argument 1, got %v (%T)", f.function, args[0], args[0])
+	}
+	to, err := parseUnit(e.stringArg(f, args, 1))
+	e.check(err)
+	from := e.unit(q)
+	if _, _, err := e.config.registry().measure(to); err != nil {
+		e.error("%s: %s", f.function, err)
+	}
+	if !e.compatible(from, to) {
+		e.error("%s cannot convert %s to %s", f.function, from, to)
+	}
+	return Quantity{e.convertQuantity(q, from, to), to.String()}
+}
+
+// parseQuantity parses the unit of a quantity literal, from the bracket open
+// that follows its number x up to and including the closing bracket.
+func (p *parser) parseQuantity(x expr, open *token) expr {
+	// The unit is lexed as an expression, but parsed as the text of its
+	// tokens, with a space where any separated them.
+	p.consume()
+	var b strings.Builder
+	end := open.end
+	for {
+		t := p.lexer.peekToken()
+		if t.typ == tokenEOF {
+			p.errorf(open, CodeUnclosedBracket, "Unclosed bracket")
+			return p.bad(open)
+		}
+		p.consume()
+		if t.typ == tokenRightBracket {
+			end = t.end
+			break
+		}
+		if b.Len() > 0 && t.pos > end {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.val)
+		end = t.end
+	}
+	c, err := parseUnit(b.String())
+	if err == nil {
+		_, _, err = p.config.registry().measure(c)
+	}
+	if err != nil {
+		p.errorf(&token{pos: open.pos, end: end}, CodeBadLiteral, "Invalid quantity literal: %s", err)
+		return p.bad(open)
+	}
+	if len(c) == 0 {
+		p.errorf(&token{pos: open.pos, end: end}, CodeBadLiteral, "Invalid quantity literal: unit %q cancels out", b.String())
+		return p.bad(open)
+	}
+	if _, ok := x.(*badExpr); ok {
+		return x
+	}
+	return &quantityExpr{x, c.String()}
+}
+
+// checkUnits reports a left op right whose operands are known, before
+// evaluation, to have units that op cannot combine.
+func (p *parser) checkUnits(op *token, left, right expr) {
+	switch op.typ {
+	case tokenPlus, tokenMinus, tokenPercent,
+		tokenLessThan, tokenLessOrEqual, tokenGreaterThan, tokenGreaterOrEqual:
+	default:
+		return
+	}
+	l, lok := p.unitOf(left)
+	r, rok := p.unitOf(right)
+	if !lok || !rok {
+		return
+	}
+	_, ld, lerr := p.config.registry().measure(l)
+	_, rd, rerr := p.config.registry().measure(r)
+	if lerr == nil && rerr == nil && !ld.equal(rd) {
+		p.errorf(op, CodeUnitMismatch, "Incompatible units for %s: %s and %s", op, unitLabel(l), unitLabel(r))
+	}
+}
+
+// unitOf returns the unit that x evaluates to, as far as the literals it is
+// made of determine it. ok is false if they do not.
+func (p *parser) unitOf(x expr) (c compound, ok bool) {
+	switch x := x.(type) {
+	case *quantityExpr:
+		c, err := parseUnit(x.unit)
+		return c, err == nil
+	case *durationExpr:
+		return compound{{"s", 1}}, true
+	case *unaryExpr:
+		if x.op.typ == tokenMinus || x.op.typ == tokenPlus {
+			return p.unitOf(x.expr)
+		}
+	case *binaryExpr:
+		l, lok := p.unitOf(x.left)
+		r, rok := p.unitOf(x.right)
+		if !lok || !rok {
+			return nil, false
+		}
+		switch x.op.typ {
+		case tokenStar:
+			return l.times(r), true
+		case tokenSlash:
+			return l.times(r.inverse()), true
+		case tokenPlus, tokenMinus, tokenPercent:
+			return l, true
+		}
+	default:
+		return nil, isNumber(x) && !isComplex(x)
+	}
+	return nil, false
+}
+
+// isComplex reports whether x is an imaginary literal.
+func isComplex(x expr) bool {
+	_, ok := x.(*complexExpr)
+	return ok
+}
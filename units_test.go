@@ -0,0 +1,223 @@
+package gocalc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnits(t *testing.T) {
+	params := MapEnv(map[string]interface{}{
+		"span":  Quantity{3, "ft"},
+		"time":  &Quantity{int32(2), "s"},
+		"bad":   Quantity{"3", "m"},
+		"weird": Quantity{3, "parsec"},
+		"x":     2,
+	})
+
+	for _, test := range []expressionTest{
+		{true, "5[km]", Quantity{int64(5), "km"}},
+		{true, "2.5[kg m/s^2]", Quantity{2.5, "kg*m/s^2"}},
+		{true, "5[km] + 3[km]", Quantity{int64(8), "km"}},
+		{true, "5[km] + 300[m]", Quantity{5.3, "km"}},
+		{true, "300[m] + 5[km]", Quantity{5300.0, "m"}},
+		{true, "5[km] - 500[m]", Quantity{4.5, "km"}},
+		{true, "10[kg] * 2.5[m/s^2]", Quantity{25.0, "kg*m/s^2"}},
+		{true, "10[kg] * 2.5[m/s^2] == 25[N]", true},
+		{true, "2[m] * 3[m]", Quantity{int64(6), "m^2"}},
+		{true, "10[m] / 4[s]", Quantity{int64(2), "m/s"}},
+		{true, "10.0[m] / 4[s]", Quantity{2.5, "m/s"}},
+		{true, "1.0 / 4[s]", Quantity{0.25, "s^-1"}},
+		{true, "2 * 5[km]", Quantity{int64(10), "km"}},
+		{true, "5[km] * x", Quantity{int64(10), "km"}},
+		{true, "5.0[km] / 2", Quantity{2.5, "km"}},
+		{true, "10[m] / 2[m]", int64(5)},
+		{true, "1[km] / 1[m]", 1000.0},
+		{true, "1[km] / 8[m]", 125.0},
+		{true, "1[Hz] * 3[s]", int64(3)},
+		{true, "-5[m]", Quantity{int64(-5), "m"}},
+		{true, "+5[m]", Quantity{int64(5), "m"}},
+		{true, "1[km] > 999[m]", true},
+		{true, "1[km] == 1000[m]", true},
+		{true, "1[kWh] == 3.6[MJ]", true},
+		{true, "1[L] == 1000[mL]", true},
+		{true, "1[Ω] * 2[A] == 2[V]", true},
+		{true, "1[m] == 1[s]", false},
+		{true, "1[m] != 1", true},
+		{true, "1[mi] < 2[km] < 1[mi] + 1[km]", true},
+		{true, "1[m] in [1[s], 100[cm]]", true},
+		{true, "sort([1[km], 1[m], 1[mi]])[0]", Quantity{int64(1), "m"}},
+		{true, "max(1[km], 900[m])", Quantity{int64(1), "km"}},
+		{true, "sum([1[m], 3[m]])", Quantity{int64(4), "m"}},
+		{true, "sum(1[km], 500[m])", Quantity{1.5, "km"}},
+		{true, "avg([1[m], 2[m]])", Quantity{1.5, "m"}},
+		{true, "avg([1[km], null, 500[m]])", Quantity{0.75, "km"}},
+		{true, `to(1[mi], "km")`, Quantity{1.609344, "km"}},
+		{true, `to(72[km/h], "m/s")`, Quantity{20.0, "m/s"}},
+		{true, `to(1[bar], "kPa")`, Quantity{100.0, "kPa"}},
+		{true, `to(25[N], "kg m s^-2")`, Quantity{int64(25), "kg*m/s^2"}},
+		{true, `to(null, "m")`, Null},
+		{true, "span", Quantity{int64(3), "ft"}},
+		{true, "span + 1[in]", Quantity{3.0833333333333335, "ft"}},
+		{true, "span / time", Quantity{int64(1), "ft/s"}},
+		{true, "span.Unit", "ft"},
+		{true, "span + null", Null},
+		{true, "5[h] + 30m", Quantity{5.5, "h"}},
+		{true, "30m + 5[h]", 5*time.Hour + 30*time.Minute},
+		{true, "90s - 1[min]", 30 * time.Second},
+		{true, "1500ms + 1[s]", 2500 * time.Millisecond},
+		{true, "30m * 2[m/s]", Quantity{int64(3600), "m"}},
+		{true, "1.0[km] / 20s", Quantity{0.05, "km/s"}},
+		{true, "1h / 1[min]", 60.0},
+		{true, "1[h] == 60m", true},
+		{true, "60m == 1[h]", true},
+		{true, "1[h] > 59m", true},
+		{true, "1[m] == 1s", false},
+		{true, `to(90m, "s")`, Quantity{int64(5400), "s"}},
+		{true, `to(90m, "h")`, Quantity{1.5, "h"}},
+		{true, `duration(1.5[h])`, 90 * time.Minute},
+		{true, `duration(250[ms])`, 250 * time.Millisecond},
+		{false, "5[m] + 2[s]", nil},
+		{false, "5[m] + 2", nil},
+		{false, "2 - 5[m]", nil},
+		{false, "5[m] < 2[s]", nil},
+		{false, "1[m] < 2[m] < 3[s]", nil},
+		{false, "10[kg] * 2.5[m/s^2] - 1[J]", nil},
+		{false, "5[m] % 2", nil},
+		{false, "span + time", nil},
+		{false, "5[m] + 30m", nil},
+		{false, "30m + 5[m]", nil},
+		{false, "1[h] + 1", nil},
+		{false, `to(90m, "m")`, nil},
+		{false, `duration(5[m])`, nil},
+		{false, `duration(1e12[h])`, nil},
+		{false, "sum([1[m], 1[s]])", nil},
+		{false, "sum([1[m], 1])", nil},
+		{false, "span + 1", nil},
+		{false, "1 < span", nil},
+		{false, `span + "a"`, nil},
+		{false, "span * 2i", nil},
+		{false, "bad + 1[m]", nil},
+		{false, "weird * 2", nil},
+		{false, `to(5[m], "s")`, nil},
+		{false, `to(5, "m")`, nil},
+		{false, `to(5[m], "furlong")`, nil},
+		{false, `to(5[m], "m^")`, nil},
+		{false, "5[parsec]", nil},
+		{false, "5[m^0]", nil},
+		{false, "5[m/m]", nil},
+		{false, "5[]", nil},
+		{false, "5[2]", nil},
+		{false, "5[m", nil},
+		{false, "5[m*]", nil},
+		{false, "5[m s^2x]", nil},
+		{false, "99999999999999999999[m]", nil},
+		{false, "5 [m]", nil},
+	} {
+		checkEvaluation(t, test, params, nil)
+	}
+}
+
+func TestUnitDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		code string
+	}{
+		{"5[m] + 2[s]", CodeUnitMismatch},
+		{"-5[m] - 2 * 3", CodeUnitMismatch},
+		{"1[m] < 2[m] < 3[s]", CodeUnitMismatch},
+		{"10[kg] * 2[m] / 1[s^2] >= 1[J]", CodeUnitMismatch},
+		{"5[m] + 30m", CodeUnitMismatch},
+		{"5[parsec]", CodeBadLiteral},
+		{"5[m/m]", CodeBadLiteral},
+		{"5[m", CodeUnclosedBracket},
+	} {
+		diags := Check(test.src)
+		if len(diags) != 1 || diags[0].Code != test.code {
+			t.Errorf("Check(%q) = %v, expected one %s diagnostic", test.src, diags, test.code)
+		}
+	}
+	for _, src := range []string{"5[m] + x", "1[km] / 1[m] + 1", "5[m] * 2[s] == 1[m s]", "1[N] - 1[kg m/s^2]", "5[h] + 30m"} {
+		if diags := Check(src); len(diags) != 0 {
+			t.Errorf("Check(%q) = %v, expected no diagnostics", src, diags)
+		}
+	}
+}
+
+func TestUnitRegistry(t *testing.T) {
+	u := NewUnits()
+	if err := u.AddBase("px"); err != nil {
+		t.Fatalf("AddBase: %v", err)
+	}
+	if err := u.Add("nmi", 1852, "m"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := u.Add("knot", 1, "nmi/h"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	for _, err := range []error{
+		u.AddBase(""),
+		u.AddBase("px"),
+		u.AddBase("2x"),
+		u.AddBase("km"),
+		u.Add("m", 1, "m"),
+		u.Add("k px", 1000, "px"),
+		u.Add("furlong", 0, "m"),
+		u.Add("furlong", -1, "m"),
+		u.Add("furlong", 201.168, "chain"),
+		u.Add("furlong", 201.168, "m^"),
+	} {
+		if err == nil {
+			t.Errorf("Invalid unit added without error")
+		}
+	}
+
+	for _, test := range []expressionTest{
+		{true, "100[px] * 2", Quantity{int64(200), "px"}},
+		{true, "100[px] / 10[px]", int64(10)},
+		{true, `to(3[nmi], "m")`, Quantity{5556.0, "m"}},
+		{true, "10[knot] < 19[km/h]", true},
+		{true, "5[km] + 300[m]", Quantity{5.3, "km"}},
+		{false, "100[px] + 1[m]", nil},
+		{false, "1[kpx]", nil},
+		{false, "1[k px]", nil},
+	} {
+		checkEvaluation(t, test, nil, nil, WithUnits(u))
+	}
+
+	if _, err := NewExpr("5[px]"); err == nil {
+		t.Errorf("Expression \"5[px]\" compiled without WithUnits")
+	}
+}
+
+func TestQuantityPrinter(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		printed string
+	}{
+		{"5[km]", "5[km]"},
+		{"2.5[kg m s^-2]", "2.5[kg*m/s^2]"},
+		{"1[ s^-1 ]", "1[s^-1]"},
+		{"1[m/s*s]", "1[m]"},
+		{"-5[m] * 2", "-5[m] * 2"},
+		{"5[m][0]", "5[m][0]"},
+		{"5 [0]", "(5)[0]"},
+		{"1.5 [0]", "(1.5)[0]"},
+		{"(1.5).x", "(1.5).x"},
+	} {
+		e, err := NewExpr(test.expr)
+		if err != nil {
+			t.Errorf("Expression \"%s\": Cannot test; lexer or parser error: %v", test.expr, err)
+			continue
+		}
+		if s := e.String(); s != test.printed {
+			t.Errorf("Expression \"%s\": printed as %q, expected %q", test.expr, s, test.printed)
+			continue
+		}
+		printed, err := NewExpr(e.String())
+		if err != nil {
+			t.Errorf("Expression \"%s\": printed %q, which does not compile: %v", test.expr, e, err)
+		} else if dump(printed) != dump(e) {
+			t.Errorf("Expression \"%s\": printed %q, which compiles to a different tree", test.expr, e)
+		}
+	}
+}